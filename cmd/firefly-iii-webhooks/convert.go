@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+)
+
+// convertCommand translates the configuration file given as first argument into another format,
+// writing it to the file given as second argument or to the standard output. It returns the process exit code.
+func convertCommand(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s convert [flags] <input file> [output file]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	to := fs.String("to", "", "Format to convert to (json, yaml or toml), by default the one of the output file extension")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 || fs.NArg() == 1 && *to == "" {
+		fs.Usage()
+		return 2
+	}
+
+	input, output := fs.Arg(0), fs.Arg(1)
+	format := firefly.ConfigFormatOf(output)
+	if *to != "" {
+		var err error
+		format, err = firefly.ParseConfigFormat(*to)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	// Only valid configurations are converted, reporting errors with their position in the input file
+	if _, err := firefly.LoadConfig(input); err != nil {
+		printConfigErrors(os.Stderr, err)
+		return 1
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	converted, err := firefly.ConvertConfig(data, firefly.ConfigFormatOf(input), format)
+	if err != nil {
+		printConfigErrors(os.Stderr, err)
+		return 1
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(converted)
+	} else {
+		err = os.WriteFile(output, converted, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
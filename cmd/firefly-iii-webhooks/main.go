@@ -1,34 +1,68 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/akyrey/firefly-iii-webhooks/internal"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/assert"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/notify"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/prettylog"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/store"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "convert":
+			os.Exit(convertCommand(os.Args[2:]))
+		}
+	}
+
 	config := internal.Config{}
-	config.Parse()
+	err := config.Parse()
+	assert.NoError(err, "Invalid configuration")
 
 	logger := slog.New(prettylog.NewHandler(&slog.HandlerOptions{
 		AddSource: true,
 		Level:     config.LogLevel,
 	}))
 
+	var notifier notify.Sink = notify.NewLogSink(logger)
+	if config.NotifyUrl != "" {
+		notifier = notify.NewWebhookSink(config.NotifyUrl)
+	}
+
+	state, err := store.Open(config.StateFile)
+	assert.NoError(err, "Unable to open state file", "file", config.StateFile)
+
 	app := &internal.Application{
-		Config: config,
-		FireflyClient: firefly.NewFirefly(
-			config.FireflyBaseUrl,
-			firefly.WithApiKey(config.FireflyApiKey),
-		),
-		FireflyConfig: firefly.ReadConfig(config.FireflyConfigFile),
+		Config:        config,
+		FireflyClient: firefly.NewFirefly(config.FireflyBaseUrl, config.FireflyOptions(logger)...),
 		Logger:        logger,
+		Notifier:      notifier,
+		Store:         state,
+	}
+	fireflyConfig, err := app.LoadFireflyConfig()
+	if err != nil {
+		printConfigErrors(os.Stderr, err)
+		logger.Error("Invalid Firefly configuration file", "file", config.FireflyConfigFile)
+		os.Exit(1)
 	}
+	app.FireflyConfig.Store(fireflyConfig)
+
+	go func() {
+		err := app.WatchConfig(context.Background())
+		if err != nil {
+			logger.Error("Configuration hot reload disabled", "error", err)
+		}
+	}()
 
 	srv := &http.Server{
 		Addr:    config.Addr,
@@ -41,6 +75,6 @@ func main() {
 
 	logger.Info("starting server", "addr", srv.Addr)
 
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
 	assert.NoError(err, "server failed to start", "error", err)
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/akyrey/firefly-iii-webhooks/internal"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+)
+
+// validateCommand checks the configuration file given as argument, or the configured one,
+// printing every error found. Names are resolved only when the Firefly api key is configured.
+// It returns the process exit code.
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate [flags] [config file]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	config := internal.Config{}
+	if err := config.ParseArgs(fs, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	file := config.FireflyConfigFile
+	if fs.NArg() > 0 {
+		file = fs.Arg(0)
+	}
+
+	var opts []firefly.LoadOption
+	if config.FireflyApiKey != "" {
+		client := firefly.NewFirefly(config.FireflyBaseUrl, config.FireflyOptions(slog.Default())...)
+		opts = config.LoadOptions(firefly.NewNameResolver(client))
+	}
+	fireflyConfig, err := firefly.LoadConfig(file, opts...)
+	if err == nil {
+		err = config.CheckFireflyConfig(fireflyConfig)
+	}
+	if err != nil {
+		printConfigErrors(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s is valid\n", file)
+	return 0
+}
+
+// printConfigErrors prints each configuration error on its own line.
+func printConfigErrors(w io.Writer, err error) {
+	var configErrors firefly.ConfigErrors
+	if !errors.As(err, &configErrors) {
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	for _, configErr := range configErrors {
+		fmt.Fprintln(w, configErr)
+	}
+}
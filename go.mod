@@ -3,15 +3,17 @@ module github.com/akyrey/firefly-iii-webhooks
 go 1.25.7
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/jinzhu/copier v0.4.0
 	github.com/justinas/alice v1.2.0
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/crypto v0.49.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+)
+
+// maxConfigUploadSize is the size limit of the configurations uploaded to the admin API.
+const maxConfigUploadSize = 1 << 20
+
+// ConfigEntry is an action configuration value returned by the admin API, with its secrets redacted.
+type ConfigEntry struct {
+	Config string              `json:"config"`
+	Value  firefly.ConfigValue `json:"value"`
+}
+
+// requireAdmin only lets requests authenticated with the admin token through. The admin API doesn't exist when
+// no token is configured.
+func (a *Application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Config.AdminToken == "" {
+			a.clientError(w, r, http.StatusNotFound)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.Config.AdminToken)) != 1 {
+			a.Logger.Warn("Unauthorized admin request", "ip", r.RemoteAddr, "uri", r.URL.RequestURI())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			a.clientError(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listConfig returns the configuration currently used, with secrets redacted.
+func (a *Application) listConfig(w http.ResponseWriter, r *http.Request) {
+	a.clientResponse(w, r, http.StatusOK, a.FireflyConfig.Load())
+}
+
+// setConfigEnabled returns a handler enabling or disabling the configuration value at the {type} and {index} of
+// the path, e.g. balance_alert and 2. The change only lasts until the configuration is reloaded from FIREFLY_CONFIG.
+func (a *Application) setConfigEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := firefly.ConfigType(r.PathValue("type"))
+		index, err := strconv.Atoi(r.PathValue("index"))
+		if err != nil {
+			a.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		a.configMu.Lock()
+		defer a.configMu.Unlock()
+		config, err := a.FireflyConfig.Load().WithEnabled(t, index, enabled)
+		if errors.Is(err, firefly.ErrFireflyConfigNotFound) {
+			a.clientError(w, r, http.StatusNotFound)
+			return
+		} else if err != nil {
+			a.serverError(w, r, err)
+			return
+		}
+		a.FireflyConfig.Store(config)
+
+		entry := ConfigEntry{Config: firefly.ConfigMatch{Type: t, Index: index}.String(), Value: (*config)[t][index]}
+		a.Logger.Info("Configuration changed by admin", "config", entry.Config, "enabled", enabled)
+		a.clientResponse(w, r, http.StatusOK, entry)
+	}
+}
+
+// uploadConfig replaces the configuration with the one in the body, in the format given by the format query
+// parameter or the content type, JSON by default. The configuration is validated like FIREFLY_CONFIG, invalid
+// ones being rejected with their errors, and swapped atomically, requests already being handled completing with
+// the previous one. With persist=true it's written to FIREFLY_CONFIG as well, when that is a single file.
+func (a *Application) uploadConfig(w http.ResponseWriter, r *http.Request) {
+	format, err := uploadFormat(r)
+	if err != nil {
+		a.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigUploadSize))
+	if err != nil {
+		a.clientError(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+	persist := r.URL.Query().Get("persist") == "true"
+
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	if persist && !a.configIsFile() {
+		a.Logger.Error("Can't persist the configuration, FIREFLY_CONFIG isn't a single file", "file", a.Config.FireflyConfigFile)
+		a.clientError(w, r, http.StatusConflict)
+		return
+	}
+	resolver := firefly.NewNameResolverContext(r.Context(), a.FireflyClient)
+	config, err := firefly.DecodeConfig(data, format, a.Config.LoadOptions(resolver)...)
+	if err == nil {
+		err = a.Config.CheckFireflyConfig(config)
+	}
+	var configErrors firefly.ConfigErrors
+	if errors.As(err, &configErrors) {
+		a.clientResponse(w, r, http.StatusUnprocessableEntity, configErrors)
+		return
+	} else if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	if persist {
+		// The file is written in its own format, the watcher then reloads the same configuration
+		fileFormat := firefly.ConfigFormatOf(a.Config.FireflyConfigFile)
+		if fileFormat != format {
+			data, err = firefly.ConvertConfig(data, format, fileFormat)
+			if err != nil {
+				a.serverError(w, r, err)
+				return
+			}
+		}
+		if err = writeFileAtomic(a.Config.FireflyConfigFile, data); err != nil {
+			a.serverError(w, r, err)
+			return
+		}
+	}
+	a.FireflyConfig.Store(config)
+	a.Logger.Info("Configuration replaced by admin", "persisted", persist)
+	a.clientResponse(w, r, http.StatusOK, config)
+}
+
+// uploadFormat returns the format of an uploaded configuration.
+func uploadFormat(r *http.Request) (firefly.ConfigFormat, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return firefly.ParseConfigFormat(format)
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "yaml"):
+		return firefly.YAML, nil
+	case strings.Contains(contentType, "toml"):
+		return firefly.TOML, nil
+	default:
+		return firefly.JSON, nil
+	}
+}
+
+// configIsFile checks if FIREFLY_CONFIG is a single file rather than a directory or a glob pattern.
+func (a *Application) configIsFile() bool {
+	files, err := firefly.ConfigFiles(a.Config.FireflyConfigFile)
+	return err == nil && len(files) == 1 && files[0] == a.Config.FireflyConfigFile
+}
+
+// writeFileAtomic replaces the file with the data, writing them to a temporary file first so that readers never
+// see it partially written. The temporary file is hidden, so it's ignored by the configuration watcher.
+func writeFileAtomic(file string, data []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
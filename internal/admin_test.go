@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminRequest sends a request to the admin API, returning the status and the body of the response.
+func adminRequest(t *testing.T, handler http.Handler, method string, uri string, body string) (int, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	data, err := io.ReadAll(w.Result().Body)
+	require.NoError(t, err)
+
+	return w.Code, string(data)
+}
+
+func TestUploadConfigRedactedSecrets(t *testing.T) {
+	config := `{
+  "balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10}],
+  "mirror": [{"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def", "base_url": "http://mirror", "api_key": "key"}]
+}`
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(config), 0o644))
+	a := newTestApplication(t, newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {}), config)
+	a.Config = Config{AdminToken: "token", FireflyConfigFile: file, StateFile: filepath.Join(t.TempDir(), "state.json")}
+	handler := a.Routes(a.Config)
+
+	status, listed := adminRequest(t, handler, http.MethodGet, "/api/v1/admin/config", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, listed, `"secret":"[redacted]"`)
+	edited := strings.Replace(listed, `"low_threshold":10`, `"low_threshold":20`, 1)
+
+	// The secrets returned redacted must be set again, they are never taken as the actual ones
+	status, body := adminRequest(t, handler, http.MethodPut, "/api/v1/admin/config?persist=true", edited)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	var configErrors firefly.ConfigErrors
+	require.NoError(t, json.Unmarshal([]byte(body), &configErrors))
+	paths := make([]string, 0, len(configErrors))
+	for _, err := range configErrors {
+		paths = append(paths, err.Path)
+		assert.Equal(t, "must be set to the actual value, [redacted] is only returned in place of secrets", err.Message)
+	}
+	assert.Equal(t, []string{"balance_alert[0].secret", "mirror[0].secret", "mirror[0].api_key"}, paths)
+	persisted, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, config, string(persisted))
+	assert.Equal(t, firefly.Secret("abc"), (*a.FireflyConfig.Load())[firefly.BalanceAlert][0].Base().Secret)
+
+	// The actions are listed in alphabetical order, balance_alert first
+	edited = strings.Replace(edited, `"secret":"[redacted]"`, `"secret":"abc"`, 1)
+	edited = strings.Replace(edited, `"secret":"[redacted]"`, `"secret":"def"`, 1)
+	edited = strings.Replace(edited, `"api_key":"[redacted]"`, `"api_key":"key"`, 1)
+	status, body = adminRequest(t, handler, http.MethodPut, "/api/v1/admin/config?persist=true", edited)
+	require.Equal(t, http.StatusOK, status, body)
+	persisted, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(persisted), "[redacted]")
+	alert := (*a.FireflyConfig.Load())[firefly.BalanceAlert][0].(firefly.BalanceAlertConfig)
+	assert.Equal(t, firefly.Secret("abc"), alert.Secret)
+	assert.Equal(t, 20.0, *alert.LowThreshold)
+}
@@ -1,19 +1,35 @@
 package internal
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"sync"
+	"sync/atomic"
 
 	"github.com/akyrey/firefly-iii-webhooks/pkg/assert"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/notify"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/store"
 )
 
 type Application struct {
 	FireflyClient *firefly.Firefly
-	FireflyConfig *firefly.Config
-	Logger        *slog.Logger
-	Config        Config
+	// FireflyConfig holds the current configuration, replaced as a whole when reloaded.
+	FireflyConfig atomic.Pointer[firefly.Config]
+	// configMu serializes the changes to the configuration made through the admin API.
+	configMu sync.Mutex
+	Logger   *slog.Logger
+	Notifier notify.Sink
+	Store    *store.Store
+	Config   Config
+	// mirrorClients caches the clients used to reach mirror instances, indexed by base url and api key.
+	mirrorClients map[string]*firefly.Firefly
+	mirrorMu      sync.Mutex
+	// piggyBankLocks serializes the updates of each piggy bank, indexed by id.
+	piggyBankLocks map[string]*sync.Mutex
+	piggyBankMu    sync.Mutex
 }
 
 func (a *Application) serverError(w http.ResponseWriter, r *http.Request, err error) {
@@ -46,10 +62,12 @@ func (a *Application) formatErrorMessage(w http.ResponseWriter, r *http.Request,
 	return message
 }
 
+// clientResponse will write the status and, when given, the data encoded as JSON.
 func (a *Application) clientResponse(w http.ResponseWriter, r *http.Request, status int, data ...any) {
 	w.WriteHeader(status)
-	// err := json.NewEncoder(w).Encode(models.DataResponse{Data: data})
-	// if err != nil {
-	// 	serverError(logger, w, r, err)
-	// }
+	if len(data) == 0 {
+		return
+	}
+	err := json.NewEncoder(w).Encode(data[0])
+	assert.NoError(err, "Unable to write response", "error", err)
 }
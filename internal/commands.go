@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+)
+
+// runCommands will run every command found in the notes of the transaction, updating the notes
+// and, for split commands, the amount of the copy of the transaction to send back to Firefly.
+func (a *Application) runCommands(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	config firefly.NotesCommandsConfig,
+	t *models.Transaction,
+	updated *models.Transaction,
+) {
+	commands := firefly.ParseCommands(*t.Notes)
+	// Transactions created by commands must not carry the commands, otherwise they would run again
+	cleanNotes := strings.TrimSpace(firefly.ReplaceCommands(*t.Notes, func(int, firefly.Command) string {
+		return ""
+	}))
+	source := *t
+	source.Notes = &cleanNotes
+
+	succeeded := false
+	results := make([]string, len(commands))
+	for i, c := range commands {
+		var result string
+		var err error
+		switch c.Name {
+		case firefly.SPLIT_COMMAND:
+			result, err = a.splitCommand(ctx, fireflyConfig, msg, config, &source, updated, c)
+		case firefly.CASHBACK_COMMAND:
+			result, err = a.cashbackCommand(ctx, fireflyConfig, msg, &source, c)
+		case firefly.TRANSFER_COMMAND:
+			result, err = a.transferCommand(ctx, fireflyConfig, msg, config, &source, c)
+		}
+		if err != nil {
+			a.Logger.Error("Failed running command", "command", c.Raw, "error", err)
+			results[i] = fmt.Sprintf("✗ %s: %s", c.Raw, err)
+			continue
+		}
+
+		a.Logger.Debug("Command completed successfully", "command", c.Raw, "result", result)
+		succeeded = true
+		if config.Annotate {
+			results[i] = fmt.Sprintf("✓ %s: %s", c.Raw, result)
+		}
+	}
+
+	notes := strings.TrimSpace(firefly.ReplaceCommands(*t.Notes, func(i int, _ firefly.Command) string {
+		return results[i]
+	}))
+	updated.Notes = &notes
+	if succeeded {
+		updated.Tags = append(updated.Tags, fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.NotesCommands))
+	}
+}
+
+// splitCommand will move part of the transaction amount to a new withdrawal from another account,
+// e.g. "!split alice 50%", using the split ticket configuration to link them.
+func (a *Application) splitCommand(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	config firefly.NotesCommandsConfig,
+	t *models.Transaction,
+	updated *models.Transaction,
+	c firefly.Command,
+) (string, error) {
+	if len(c.Args) != 2 {
+		return "", firefly.ErrFireflyInvalidCommand
+	}
+	accountID, err := config.AccountID(c.Args[0])
+	if err != nil {
+		return "", err
+	}
+	amount, err := firefly.ParseCommandAmount(c.Args[1])
+	if err != nil {
+		return "", err
+	}
+	configValue, err := fireflyConfig.FindConfig(firefly.SplitTicket, msg)
+	if err != nil {
+		return "", err
+	}
+	splitConfig, ok := configValue.(firefly.SplitTicketConfig)
+	if !ok {
+		return "", firefly.ErrFireflyConfigNotFound
+	}
+
+	transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
+	if err != nil {
+		return "", err
+	}
+	currentAmount, err := strconv.ParseFloat(strings.TrimSpace(updated.Amount), 64)
+	if err != nil {
+		return "", err
+	}
+	// Round the portion so the remaining amount matches what Firefly stores
+	portion, err := strconv.ParseFloat(
+		fmt.Sprintf("%.[2]*[1]f", amount.Of(transactionAmount), t.CurrencyDecimalPlaces),
+		64,
+	)
+	if err != nil {
+		return "", err
+	}
+	if portion >= currentAmount {
+		return "", fmt.Errorf("%w: split amount exceeds transaction amount", firefly.ErrFireflyInvalidCommand)
+	}
+
+	splitConfig.DestinationAccountId = accountID
+	splitConfig.DestinationCurrencyId = t.CurrencyID
+	splitConfig.DestinationCurrencyDecimalPlaces = &t.CurrencyDecimalPlaces
+	created, err := a.createSplitTransaction(ctx, t, portion, splitConfig)
+	if err != nil {
+		return "", err
+	}
+
+	remaining := currentAmount - portion
+	updated.Amount = fmt.Sprintf("%.[2]*[1]f", remaining, t.CurrencyDecimalPlaces)
+	if updated.ForeignAmount != nil && updated.ForeignCurrencyDecimalPlaces != nil {
+		foreignAmount, err2 := strconv.ParseFloat(strings.TrimSpace(*updated.ForeignAmount), 64)
+		if err2 != nil {
+			return "", err2
+		}
+		foreignAmount = foreignAmount * remaining / currentAmount
+		updatedForeignAmount := fmt.Sprintf("%.[2]*[1]f", foreignAmount, *updated.ForeignCurrencyDecimalPlaces)
+		updated.ForeignAmount = &updatedForeignAmount
+	}
+
+	err = a.linkCreatedTransaction(ctx, splitConfig.LinkTypeId, t.TransactionJournalID, created)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"moved %.[2]*[1]f to account %[3]s in transaction #%[4]s",
+		portion,
+		t.CurrencyDecimalPlaces,
+		accountID,
+		created.Data.ID,
+	), nil
+}
+
+// cashbackCommand will create a cashback deposit for the transaction, e.g. "!cashback 2%",
+// overriding the amount of the cashback configuration.
+func (a *Application) cashbackCommand(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	t *models.Transaction,
+	c firefly.Command,
+) (string, error) {
+	if len(c.Args) != 1 {
+		return "", firefly.ErrFireflyInvalidCommand
+	}
+	amount, err := firefly.ParseCommandAmount(c.Args[0])
+	if err != nil {
+		return "", err
+	}
+	configValue, err := fireflyConfig.FindConfig(firefly.Cashback, msg)
+	if err != nil {
+		return "", err
+	}
+	cashbackConfig, ok := configValue.(firefly.CashbackConfig)
+	if !ok {
+		return "", firefly.ErrFireflyConfigNotFound
+	}
+
+	transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
+	if err != nil {
+		return "", err
+	}
+	cashbackConfig.Amount = amount.Of(transactionAmount)
+	created, err := a.createCashbackTransaction(ctx, t, cashbackConfig)
+	if err != nil {
+		return "", err
+	}
+
+	err = a.linkCreatedTransaction(ctx, cashbackConfig.LinkTypeId, t.TransactionJournalID, created)
+	if err != nil {
+		return "", err
+	}
+
+	currency, err := a.currency(
+		ctx,
+		cashbackConfig.DestinationAccountId,
+		cashbackConfig.DestinationCurrencyId,
+		cashbackConfig.DestinationCurrencyDecimalPlaces,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"created cashback of %.[2]*[1]f in transaction #%[3]s",
+		cashbackConfig.Amount,
+		currency.DecimalPlaces,
+		created.Data.ID,
+	), nil
+}
+
+// transferCommand will create a transfer to another account, e.g. "!transfer savings 10",
+// overriding the destination account and amount of the transfer configuration.
+func (a *Application) transferCommand(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	config firefly.NotesCommandsConfig,
+	t *models.Transaction,
+	c firefly.Command,
+) (string, error) {
+	if len(c.Args) != 2 {
+		return "", firefly.ErrFireflyInvalidCommand
+	}
+	accountID, err := config.AccountID(c.Args[0])
+	if err != nil {
+		return "", err
+	}
+	amount, err := firefly.ParseCommandAmount(c.Args[1])
+	if err != nil {
+		return "", err
+	}
+	configValue, err := fireflyConfig.FindConfig(firefly.Transfer, msg)
+	if err != nil {
+		return "", err
+	}
+	transferConfig, ok := configValue.(firefly.TransferConfig)
+	if !ok {
+		return "", firefly.ErrFireflyConfigNotFound
+	}
+
+	transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
+	if err != nil {
+		return "", err
+	}
+	transferConfig.DestinationAccountId = accountID
+	transferAmount := amount.Of(transactionAmount)
+	created, err := a.createTransferTransaction(ctx, t, transferAmount, transferConfig)
+	if err != nil {
+		return "", err
+	}
+
+	err = a.linkCreatedTransaction(ctx, transferConfig.LinkTypeId, t.TransactionJournalID, created)
+	if err != nil {
+		return "", err
+	}
+
+	currency, err := a.currency(
+		ctx,
+		transferConfig.DestinationAccountId,
+		transferConfig.DestinationCurrencyId,
+		transferConfig.DestinationCurrencyDecimalPlaces,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(
+		"transferred %.[2]*[1]f to account %[3]s in transaction #%[4]s",
+		transferAmount,
+		currency.DecimalPlaces,
+		accountID,
+		created.Data.ID,
+	), nil
+}
@@ -2,9 +2,15 @@ package internal
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
 )
 
 // Config holds basic application configuration.
@@ -13,7 +19,17 @@ type Config struct {
 	FireflyBaseUrl    string
 	FireflyConfigFile string
 	FireflyApiKey     string
-	LogLevel          slog.Level
+	FireflyTimeout    time.Duration
+	// FireflyRateLimit is the number of requests per second sent to Firefly at most, 0 when unlimited.
+	FireflyRateLimit float64
+	// FireflyMaxConcurrent is the number of requests in flight to Firefly at most, 0 when unlimited.
+	FireflyMaxConcurrent int
+	// FireflyVerifyIDs checks the ids of the configuration exist in Firefly when loading it.
+	FireflyVerifyIDs bool
+	NotifyUrl        string
+	StateFile        string
+	AdminToken       string
+	LogLevel         slog.Level
 }
 
 const (
@@ -27,28 +43,154 @@ const (
 	CONFIG_FILE = "firefly-config"
 	// API_KEY Firefly III API key to use.
 	API_KEY = "firefly-api-key"
+	// TIMEOUT Timeout of each request to the Firefly III API.
+	TIMEOUT = "firefly-timeout"
+	// RATE_LIMIT Requests per second sent to the Firefly III API at most.
+	RATE_LIMIT = "firefly-rate-limit"
+	// MAX_CONCURRENT Requests in flight to the Firefly III API at most.
+	MAX_CONCURRENT = "firefly-max-concurrent"
+	// VERIFY_IDS Check the ids of the configuration exist in Firefly III when loading it.
+	VERIFY_IDS = "firefly-verify-ids"
+	// API_KEY_FILE File containing the Firefly III API key, e.g. a Docker secret.
+	API_KEY_FILE = "firefly-api-key-file"
+	// NOTIFY_URL HTTP endpoint notifications are posted to.
+	NOTIFY_URL = "notify-url"
+	// STATE_FILE JSON file used to persist state between webhook calls.
+	STATE_FILE = "state-file"
+	// ADMIN_TOKEN Bearer token required by the admin API, disabled when empty.
+	ADMIN_TOKEN = "admin-token"
+	// ADMIN_TOKEN_FILE File containing the admin API token, e.g. a Docker secret.
+	ADMIN_TOKEN_FILE = "admin-token-file"
 )
 
 // Parse parses the command line flags and stores the result in the Config struct.
-func (c *Config) Parse() {
-	parseFlagOrEnv(&c.Addr, ADDRESS, ":4000", "HTTP network address")
-	parseFlagOrEnv(&c.FireflyBaseUrl, BASE_URL, "http://firefly_iii_core:8080", "Base URL for the Firefly III API")
-	parseFlagOrEnv(&c.FireflyConfigFile, CONFIG_FILE, "./config.json", "JSON configuration file for Firefly webhooks")
-	parseFlagOrEnv(&c.FireflyApiKey, API_KEY, "", "Firefly III API key to use")
+func (c *Config) Parse() error {
+	return c.ParseArgs(flag.CommandLine, os.Args[1:])
+}
+
+// ParseArgs parses the arguments using the given flag set and stores the result in the Config struct.
+func (c *Config) ParseArgs(fs *flag.FlagSet, args []string) error {
+	parseFlagOrEnv(fs, &c.Addr, ADDRESS, ":4000", "HTTP network address")
+	parseFlagOrEnv(fs, &c.FireflyBaseUrl, BASE_URL, "http://firefly_iii_core:8080", "Base URL for the Firefly III API")
+	parseFlagOrEnv(fs, &c.FireflyConfigFile, CONFIG_FILE, "./config.json", "JSON, YAML or TOML configuration file, directory or glob pattern for Firefly webhooks")
+	parseFlagOrEnv(fs, &c.FireflyApiKey, API_KEY, "", "Firefly III API key to use")
+	var apiKeyFile string
+	parseFlagOrEnv(fs, &apiKeyFile, API_KEY_FILE, "", "File containing the Firefly III API key, e.g. a Docker secret")
+	parseFlagOrEnv(fs, &c.NotifyUrl, NOTIFY_URL, "", "HTTP endpoint notifications are posted to, logged when empty")
+	parseFlagOrEnv(fs, &c.StateFile, STATE_FILE, "", "JSON file used to persist state between webhook calls, kept in memory when empty")
+	parseFlagOrEnv(fs, &c.AdminToken, ADMIN_TOKEN, "", "Bearer token required by the admin API, disabled when empty")
+	var adminTokenFile string
+	parseFlagOrEnv(fs, &adminTokenFile, ADMIN_TOKEN_FILE, "", "File containing the admin API token, e.g. a Docker secret")
+	var timeout string
+	parseFlagOrEnv(fs, &timeout, TIMEOUT, "10s", "Timeout of each request to the Firefly III API, e.g. 30s")
+	var rateLimit string
+	parseFlagOrEnv(fs, &rateLimit, RATE_LIMIT, "0", "Requests per second sent to the Firefly III API at most, unlimited when 0")
+	var maxConcurrent string
+	parseFlagOrEnv(fs, &maxConcurrent, MAX_CONCURRENT, "0", "Requests in flight to the Firefly III API at most, unlimited when 0")
+	var verifyIDs string
+	parseFlagOrEnv(fs, &verifyIDs, VERIFY_IDS, "false", "Check the ids of the configuration exist in Firefly III when loading it")
 	var logLevel string
-	parseFlagOrEnv(&logLevel, LOG_LEVEL, "debug", "Log message level")
+	parseFlagOrEnv(fs, &logLevel, LOG_LEVEL, "debug", "Log message level")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
 	level, err := parseLogLevel(logLevel)
 	if err != nil {
 		level = slog.LevelError
 	}
 	c.LogLevel = level
 
-	flag.Parse()
+	c.FireflyTimeout, err = time.ParseDuration(timeout)
+	if err != nil || c.FireflyTimeout <= 0 {
+		return fmt.Errorf("%s must be a positive duration, e.g. 30s, got %q", flagToEnv(TIMEOUT), timeout)
+	}
+	c.FireflyRateLimit, err = strconv.ParseFloat(rateLimit, 64)
+	if err != nil || c.FireflyRateLimit < 0 {
+		return fmt.Errorf("%s must be a positive number, got %q", flagToEnv(RATE_LIMIT), rateLimit)
+	}
+	c.FireflyMaxConcurrent, err = strconv.Atoi(maxConcurrent)
+	if err != nil || c.FireflyMaxConcurrent < 0 {
+		return fmt.Errorf("%s must be a positive integer, got %q", flagToEnv(MAX_CONCURRENT), maxConcurrent)
+	}
+	c.FireflyVerifyIDs, err = strconv.ParseBool(verifyIDs)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false, got %q", flagToEnv(VERIFY_IDS), verifyIDs)
+	}
+
+	if err = readSecretFile(&c.FireflyApiKey, apiKeyFile, API_KEY, API_KEY_FILE); err != nil {
+		return err
+	}
+	if err = readSecretFile(&c.AdminToken, adminTokenFile, ADMIN_TOKEN, ADMIN_TOKEN_FILE); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FireflyOptions returns the options of the client of the Firefly III instance, logging with the given logger.
+func (c *Config) FireflyOptions(logger *slog.Logger) []firefly.FireflyOption {
+	opts := []firefly.FireflyOption{
+		firefly.WithApiKey(c.FireflyApiKey),
+		firefly.WithTimeout(c.FireflyTimeout),
+		firefly.WithLogger(logger),
+	}
+	if c.FireflyRateLimit > 0 {
+		// Bursts of up to a second of requests are allowed after a quiet period
+		opts = append(opts, firefly.WithRateLimit(c.FireflyRateLimit, max(1, int(math.Ceil(c.FireflyRateLimit)))))
+	}
+	if c.FireflyMaxConcurrent > 0 {
+		opts = append(opts, firefly.WithMaxConcurrent(c.FireflyMaxConcurrent))
+	}
+
+	return opts
+}
+
+// LoadOptions returns the options loading the configuration, resolving names into ids with the given resolver.
+func (c *Config) LoadOptions(resolver firefly.NameResolver) []firefly.LoadOption {
+	opts := []firefly.LoadOption{firefly.WithNameResolver(resolver)}
+	if c.FireflyVerifyIDs {
+		opts = append(opts, firefly.WithIDVerification())
+	}
+
+	return opts
+}
+
+// CheckFireflyConfig checks the configuration can run with these settings: the mirror action needs STATE_FILE,
+// without it the ids of the mirrored transactions are lost on restart and their updates create duplicates.
+func (c *Config) CheckFireflyConfig(config *firefly.Config) error {
+	if c.StateFile == "" && len((*config)[firefly.Mirror]) > 0 {
+		return firefly.ConfigErrors{{
+			Path:    string(firefly.Mirror),
+			Message: fmt.Sprintf("requires %s to persist the ids of the mirrored transactions", flagToEnv(STATE_FILE)),
+		}}
+	}
+
+	return nil
+}
+
+// readSecretFile sets the secret to the content of the file when given, failing if the secret is set as well.
+func readSecretFile(secret *string, file, key, fileKey string) error {
+	if file == "" {
+		return nil
+	}
+	if *secret != "" {
+		return fmt.Errorf("%s and %s must not be set together", flagToEnv(key), flagToEnv(fileKey))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", flagToEnv(fileKey), err)
+	}
+	*secret = strings.TrimSpace(string(data))
+
+	return nil
 }
 
 // parseFlagOrEnv parses a flag or an environment variable.
-func parseFlagOrEnv(p *string, key, def, description string) {
-	flag.StringVar(p, envToFlag(key), getEnvOrDefault(key, def), description)
+func parseFlagOrEnv(fs *flag.FlagSet, p *string, key, def, description string) {
+	fs.StringVar(p, envToFlag(key), getEnvOrDefault(key, def), description)
 }
 
 // getEnvOrDefault returns the value of an environment variable or a default value.
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFireflyConfig(t *testing.T) {
+	mirror, err := firefly.ParseConfig([]byte(`{"mirror": [{"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS",
+  "secret": "abc", "base_url": "https://mirror.example.com", "api_key": "key"}]}`))
+	require.NoError(t, err)
+	alert, err := firefly.ParseConfig([]byte(`{"balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS",
+  "secret": "abc", "account_id": "1", "low_threshold": 10}]}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		stateFile string
+		config    *firefly.Config
+		err       string
+	}{
+		{
+			name:   "mirror without state file",
+			config: mirror,
+			err:    "mirror: requires STATE_FILE to persist the ids of the mirrored transactions",
+		},
+		{
+			name:      "mirror with state file",
+			stateFile: "state.json",
+			config:    mirror,
+		},
+		{
+			name:   "no mirror",
+			config: alert,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{StateFile: tt.stateFile}
+			err := c.CheckFireflyConfig(tt.config)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
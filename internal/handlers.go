@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"slices"
@@ -8,76 +11,183 @@ import (
 	"strings"
 
 	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/notify"
+	"github.com/jinzhu/copier"
 )
 
-// splitTicket will split a transaction related to an account into 2 transactions
-// each with a different amount and currency as defined in the configuration.
-func (a *Application) splitTicket(w http.ResponseWriter, r *http.Request) {
-	body, webhookMessage, err := a.parseRequestMessage(r)
-	if err != nil {
-		a.serverError(w, r, err)
-		return
-	}
+// errInvalidContent is returned when the content of the message isn't the one the action needs.
+var errInvalidContent = errors.New("invalid message content")
 
-	configValue, err := a.FireflyConfig.FindConfig(firefly.SplitTicket, webhookMessage)
-	if err != nil {
-		a.Logger.Debug("No configuration found", "error", err)
-		a.clientError(w, r, http.StatusNotFound)
-		return
-	}
-	config, ok := configValue.(firefly.SplitTicketConfig)
-	if !ok {
-		a.Logger.Error("Invalid configuration type", "config", configValue)
-		a.clientError(w, r, http.StatusInternalServerError)
-		return
+// ActionStatus is an enum listing the outcomes of a configuration value run by a webhook.
+type ActionStatus string
+
+const (
+	ACTION_DONE     ActionStatus = "done"
+	ACTION_FAILED   ActionStatus = "failed"
+	ACTION_SKIPPED  ActionStatus = "skipped"
+	ACTION_INACTIVE ActionStatus = "inactive"
+)
+
+// ActionResult reports the outcome of a configuration value run by a webhook.
+type ActionResult struct {
+	Config  string       `json:"config"`
+	Status  ActionStatus `json:"status"`
+	Message string       `json:"message,omitempty"`
+}
+
+// actionFunc runs a configuration value for the webhook message, returning a summary of what was done.
+// Requests to Firefly are cancelled with the context, e.g. when the webhook request is. fireflyConfig is the
+// configuration the value was found in: actions looking up other values must use it rather than the current
+// one, which may have been reloaded meanwhile.
+type actionFunc func(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	value firefly.ConfigValue,
+) (string, error)
+
+// action adapts a function running the configuration values of type T.
+func action[T firefly.ConfigValue](run func(ctx context.Context, msg firefly.WebhookMessage, config T) (string, error)) actionFunc {
+	return configAction(func(ctx context.Context, _ *firefly.Config, msg firefly.WebhookMessage, config T) (string, error) {
+		return run(ctx, msg, config)
+	})
+}
+
+// configAction adapts a function running the configuration values of type T that looks up other values.
+func configAction[T firefly.ConfigValue](
+	run func(ctx context.Context, fireflyConfig *firefly.Config, msg firefly.WebhookMessage, config T) (string, error),
+) actionFunc {
+	return func(
+		ctx context.Context,
+		fireflyConfig *firefly.Config,
+		msg firefly.WebhookMessage,
+		value firefly.ConfigValue,
+	) (string, error) {
+		config, ok := value.(T)
+		if !ok {
+			return "", fmt.Errorf("invalid configuration type %T", value)
+		}
+		return run(ctx, fireflyConfig, msg, config)
 	}
-	if config.SplitAmount == 0 {
-		a.Logger.Debug("Invalid split amount", "amount", config.SplitAmount)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+}
+
+// actions maps each ConfigType to the function running its configuration values.
+func (a *Application) actions() map[firefly.ConfigType]actionFunc {
+	return map[firefly.ConfigType]actionFunc{
+		firefly.SplitTicket:   action(a.splitTicket),
+		firefly.Cashback:      action(a.cashback),
+		firefly.Transfer:      action(a.transfer),
+		firefly.ForeignFee:    action(a.foreignFee),
+		firefly.PiggyBank:     action(a.piggyBank),
+		firefly.BalanceAlert:  action(a.balanceAlert),
+		firefly.BudgetWarning: action(a.budgetWarning),
+		firefly.Mirror:        action(a.mirror),
+		firefly.NotesCommands: configAction(a.notesCommands),
 	}
-	a.Logger.Debug("Found configuration", "config", config)
+}
 
-	a.Logger.Debug("Verifying signature", "signature", r.Header.Get("Signature"))
-	err = webhookMessage.VerifySignature(r.Header.Get("Signature"), string(body), config.Secret)
-	if err != nil {
-		a.Logger.Error("Failed validating signature", "header", r.Header.Get("Signature"), "error", err)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+// webhook returns a handler running every configuration value of the given types, or of every type when none
+// is given, that applies to the message. Values run by priority, each verifying the signature with its own
+// secret, and a failing value doesn't prevent the following ones from running. The response lists the outcome
+// of each value, including the ones inactive at the date of the message. Failures are only reported with an error
+// status, making Firefly send the message again, when no value completed: a resend would run those again.
+func (a *Application) webhook(types ...firefly.ConfigType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, webhookMessage, err := a.parseRequestMessage(r)
+		if err != nil {
+			a.serverError(w, r, err)
+			return
+		}
+
+		// Every value runs with the configuration loaded here, even if it's reloaded meanwhile
+		fireflyConfig := a.FireflyConfig.Load()
+		matches := fireflyConfig.FindConfigs(webhookMessage, types...)
+		if len(matches) == 0 {
+			a.Logger.Debug("No configuration found", "types", types)
+			a.clientError(w, r, http.StatusNotFound)
+			return
+		}
+
+		actions := a.actions()
+		signature := r.Header.Get("Signature")
+		results := make([]ActionResult, 0, len(matches))
+		verified, failed, done := false, false, false
+		for _, match := range matches {
+			result := ActionResult{Config: match.String()}
+			a.Logger.Debug("Found configuration", "config", match.String(), "value", match.Value)
+
+			err = webhookMessage.VerifySignature(signature, string(body), string(match.Value.Base().Secret))
+			if err != nil {
+				a.Logger.Error("Failed validating signature", "config", match.String(), "header", signature, "error", err)
+				result.Status, result.Message = ACTION_SKIPPED, "invalid signature"
+				results = append(results, result)
+				continue
+			}
+			verified = true
+
+			if match.Inactive != "" {
+				a.Logger.Info("Configuration inactive", "config", match.String(), "reason", match.Inactive)
+				result.Status, result.Message = ACTION_INACTIVE, match.Inactive
+				results = append(results, result)
+				continue
+			}
+
+			result.Message, err = actions[match.Type](r.Context(), fireflyConfig, webhookMessage, match.Value)
+			if err != nil {
+				failed = true
+				a.Logger.Error("Configuration failed", "config", match.String(), "error", err)
+				result.Status, result.Message = ACTION_FAILED, err.Error()
+			} else {
+				a.Logger.Debug("Configuration completed", "config", match.String(), "result", result.Message)
+				done = true
+				result.Status = ACTION_DONE
+			}
+			results = append(results, result)
+		}
+
+		switch {
+		case !verified:
+			a.clientResponse(w, r, http.StatusBadRequest, results)
+		case failed && !done:
+			a.clientResponse(w, r, http.StatusInternalServerError, results)
+		case failed:
+			a.Logger.Warn("Webhook partially failed, the failed configurations won't be run again by Firefly")
+			a.clientResponse(w, r, http.StatusMultiStatus, results)
+		default:
+			a.Logger.Debug("Webhook completed successfully")
+			a.clientResponse(w, r, http.StatusOK, results)
+		}
 	}
+}
 
-	content, ok := webhookMessage.Content.(firefly.WebhookMessageTransaction)
+// splitTicket will split a transaction related to an account into 2 transactions
+// each with a different amount and currency as defined in the configuration.
+func (a *Application) splitTicket(ctx context.Context, msg firefly.WebhookMessage, config firefly.SplitTicketConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
 	if !ok {
-		a.Logger.Error("Invalid content type", "content", webhookMessage.Content)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+		return "", errInvalidContent
 	}
 
 	count := len(content.Transactions)
 	// Only apply to single transactions and to transactions with foreign amount and currency
 	if count != 1 {
 		a.Logger.Debug("Found zero or more than one transactions", "count", count)
-		a.clientResponse(w, r, http.StatusNoContent)
-		return
+		return "not a single transaction", nil
 	}
 
 	t := content.Transactions[0]
 	if t.SourceID != config.SourceAccountId {
 		a.Logger.Debug("Transaction source id different from configured one", "transaction", t, "config", config)
-		a.clientResponse(w, r, http.StatusNoContent)
-		return
+		return "source account not matching", nil
 	}
 	if t.ForeignAmount == nil || t.ForeignCurrencyDecimalPlaces == nil {
-		a.Logger.Error("Transactions missing foreign amount info", "transaction", t)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+		return "", fmt.Errorf("transaction %s missing foreign amount info", t.TransactionJournalID)
 	}
 
 	foreignAmount, err := strconv.ParseFloat(strings.TrimSpace(*t.ForeignAmount), 64)
 	if err != nil {
-		a.Logger.Error("Invalid foreign amount", "amount", *t.ForeignAmount)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+		return "", fmt.Errorf("invalid foreign amount %q", *t.ForeignAmount)
 	}
 
 	a.Logger.Debug("Transaction meets the requirements", "transaction", t)
@@ -85,33 +195,23 @@ func (a *Application) splitTicket(w http.ResponseWriter, r *http.Request) {
 	division := math.Floor(foreignAmount / config.SplitAmount)
 	if division <= zeroWithDelta {
 		a.Logger.Debug("No need to update the transaction: division lesser than zero", "division", division)
-		a.clientResponse(w, r, http.StatusNoContent)
-		return
+		return "amount lower than split amount", nil
 	}
 	// Update this transaction setting the amount to the amount / config.SplitAmount result
-	updated, err := a.updateSplitTransaction(&t, content.ID, division, config.SplitAmount)
+	updated, err := a.updateSplitTransaction(ctx, &t, content.ID, division, config.SplitAmount)
 	if err != nil {
-		a.serverError(w, r, err)
-		return
+		return "", err
 	}
 
 	modulo := math.Mod(foreignAmount, config.SplitAmount)
 	if modulo <= zeroWithDelta {
 		a.Logger.Debug("No need to create new transaction: remainder lesser than zero", "modulo", modulo)
-		a.clientResponse(w, r, http.StatusNoContent)
-		return
+		return "transaction updated, no remainder", nil
 	}
 	// If the module isn't 0, create a new transaction with the module amount
-	created, err := a.createSplitTransaction(
-		&t,
-		modulo,
-		config.DestinationCurrencyDecimalPlaces,
-		config.DestinationAccountId,
-		config.DestinationCurrencyId,
-	)
+	created, err := a.createSplitTransaction(ctx, &t, modulo, config)
 	if err != nil {
-		a.serverError(w, r, err)
-		return
+		return "", err
 	}
 
 	// Link the created transaction with the original one using the configured link type
@@ -121,132 +221,62 @@ func (a *Application) splitTicket(w http.ResponseWriter, r *http.Request) {
 		outwardID := created.Data.Attributes.Transactions[0].TransactionJournalID
 
 		a.Logger.Debug("Linking transactions", "initial id", inwardID, "created id", outwardID, "link type", config.LinkTypeId)
-		err = a.FireflyClient.LinkTransactions(config.LinkTypeId, inwardID, outwardID)
+		err = a.FireflyClient.LinkTransactionsContext(ctx, config.LinkTypeId, inwardID, outwardID)
 		if err != nil {
-			a.serverError(w, r, err)
-			return
+			return "", err
 		}
 	} else {
 		a.Logger.Debug("Created transaction has more than one transaction, skipping linking", "created", created)
 	}
 
-	a.Logger.Debug("Webhook completed successfully")
-	a.clientResponse(w, r, http.StatusNoContent)
+	return fmt.Sprintf("transaction split, created transaction %s", created.Data.ID), nil
 }
 
 // cashback will create a new deposit transaction with a static amount
 // each with a different amount and currency as defined in the configuration.
-func (a *Application) cashback(w http.ResponseWriter, r *http.Request) {
-	body, webhookMessage, err := a.parseRequestMessage(r)
-	if err != nil {
-		a.serverError(w, r, err)
-		return
-	}
-
-	configValue, err := a.FireflyConfig.FindConfig(firefly.Cashback, webhookMessage)
-	if err != nil {
-		a.Logger.Debug("No configuration found", "error", err)
-		a.clientError(w, r, http.StatusNotFound)
-		return
-	}
-	config, ok := configValue.(firefly.CashbackConfig)
+func (a *Application) cashback(ctx context.Context, msg firefly.WebhookMessage, config firefly.CashbackConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
 	if !ok {
-		a.Logger.Error("Invalid configuration type", "config", configValue)
-		a.clientError(w, r, http.StatusInternalServerError)
-		return
-	}
-
-	if config.Amount <= 0 {
-		a.Logger.Debug("Invalid configured amount", "amount", config.Amount)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
-	}
-	a.Logger.Debug("Found configuration", "config", config)
-
-	a.Logger.Debug("Verifying signature", "signature", r.Header.Get("Signature"))
-	err = webhookMessage.VerifySignature(r.Header.Get("Signature"), string(body), config.Secret)
-	if err != nil {
-		a.Logger.Error("Failed validating signature", "header", r.Header.Get("Signature"), "error", err)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
-	}
-
-	content, ok := webhookMessage.Content.(firefly.WebhookMessageTransaction)
-	if !ok {
-		a.Logger.Error("Invalid content type", "content", webhookMessage.Content)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+		return "", errInvalidContent
 	}
 
 	var transactionIDToLink *string
 	for _, t := range content.Transactions {
 		if t.SourceID != config.SourceAccountId {
 			a.Logger.Debug("Transactions source id different from configured one", "transaction", t, "config", config)
-			a.clientResponse(w, r, http.StatusNoContent)
-			return
+			return "source account not matching", nil
 		}
 		if !slices.Contains(t.Tags, config.SourceMustHaveTag) {
 			continue
 		}
-		created, err2 := a.createCashbackTransaction(
+		created, err := a.createCashbackTransaction(
+			ctx,
 			&t,
 			config,
 		)
-		if err2 != nil {
-			a.serverError(w, r, err2)
-			return
+		if err != nil {
+			return "", err
 		}
 		transactionIDToLink = &created.Data.ID
 	}
 
-	if transactionIDToLink != nil {
-		err = a.FireflyClient.LinkTransactions(config.LinkTypeId, strconv.Itoa(content.ID), *transactionIDToLink)
-		if err != nil {
-			a.serverError(w, r, err)
-			return
-		}
+	if transactionIDToLink == nil {
+		return "no transaction with the required tag", nil
+	}
+	err := a.FireflyClient.LinkTransactionsContext(ctx, config.LinkTypeId, strconv.Itoa(content.ID), *transactionIDToLink)
+	if err != nil {
+		return "", err
 	}
 
-	a.Logger.Debug("Webhook completed successfully")
-	a.clientResponse(w, r, http.StatusNoContent)
+	return fmt.Sprintf("created cashback transaction %s", *transactionIDToLink), nil
 }
 
 // transfer will create a new transfer transaction from a source account to a destination account with an amount
 // defined by the transaction triggering the webhook.
-func (a *Application) transfer(w http.ResponseWriter, r *http.Request) {
-	body, webhookMessage, err := a.parseRequestMessage(r)
-	if err != nil {
-		a.serverError(w, r, err)
-		return
-	}
-
-	configValue, err := a.FireflyConfig.FindConfig(firefly.Transfer, webhookMessage)
-	if err != nil {
-		a.Logger.Debug("No configuration found", "error", err)
-		a.clientError(w, r, http.StatusNotFound)
-		return
-	}
-	config, ok := configValue.(firefly.TransferConfig)
-	if !ok {
-		a.Logger.Error("Invalid configuration type", "config", configValue)
-		a.clientError(w, r, http.StatusInternalServerError)
-		return
-	}
-	a.Logger.Debug("Found configuration", "config", config)
-
-	a.Logger.Debug("Verifying signature", "signature", r.Header.Get("Signature"))
-	err = webhookMessage.VerifySignature(r.Header.Get("Signature"), string(body), config.Secret)
-	if err != nil {
-		a.Logger.Error("Failed validating signature", "header", r.Header.Get("Signature"), "error", err)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
-	}
-
-	content, ok := webhookMessage.Content.(firefly.WebhookMessageTransaction)
+func (a *Application) transfer(ctx context.Context, msg firefly.WebhookMessage, config firefly.TransferConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
 	if !ok {
-		a.Logger.Error("Invalid content type", "content", webhookMessage.Content)
-		a.clientError(w, r, http.StatusBadRequest)
-		return
+		return "", errInvalidContent
 	}
 
 	var transactionIDToLink *string
@@ -258,8 +288,7 @@ func (a *Application) transfer(w http.ResponseWriter, r *http.Request) {
 		}
 		if sourceID != config.SourceAccountId {
 			a.Logger.Debug("Transactions source id different from configured one", "transaction", t, "config", config)
-			a.clientResponse(w, r, http.StatusNoContent)
-			return
+			return "source account not matching", nil
 		}
 		if !slices.Contains(t.Tags, config.SourceMustHaveTag) {
 			continue
@@ -271,9 +300,7 @@ func (a *Application) transfer(w http.ResponseWriter, r *http.Request) {
 		} else if config.ModuloAmount != nil {
 			transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
 			if err != nil {
-				a.Logger.Error("Invalid transaction amount", "amount", t.Amount)
-				a.clientError(w, r, http.StatusBadRequest)
-				return
+				return "", fmt.Errorf("invalid transaction amount %q", t.Amount)
 			}
 			amount = *config.ModuloAmount - math.Mod(transactionAmount, *config.ModuloAmount)
 		}
@@ -281,26 +308,360 @@ func (a *Application) transfer(w http.ResponseWriter, r *http.Request) {
 			a.Logger.Debug("No need to create new transaction: remainder lesser than zero", "modulo", amount)
 			continue
 		}
-		created, err2 := a.createTransferTransaction(
+		created, err := a.createTransferTransaction(
+			ctx,
 			&t,
 			amount,
 			config,
 		)
-		if err2 != nil {
-			a.serverError(w, r, err2)
-			return
+		if err != nil {
+			return "", err
 		}
 		transactionIDToLink = &created.Data.ID
 	}
 
-	if transactionIDToLink != nil {
-		err = a.FireflyClient.LinkTransactions(config.LinkTypeId, strconv.Itoa(content.ID), *transactionIDToLink)
+	if transactionIDToLink == nil {
+		return "no transfer needed", nil
+	}
+	err := a.FireflyClient.LinkTransactionsContext(ctx, config.LinkTypeId, strconv.Itoa(content.ID), *transactionIDToLink)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("created transfer transaction %s", *transactionIDToLink), nil
+}
+
+// foreignFee will create a new withdrawal transaction with the fee charged on transactions
+// made in a foreign currency, linking it to the original one.
+func (a *Application) foreignFee(ctx context.Context, msg firefly.WebhookMessage, config firefly.ForeignFeeConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	feeTag := fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.ForeignFee)
+	count := 0
+	for _, t := range content.Transactions {
+		if t.SourceID != config.SourceAccountId {
+			a.Logger.Debug("Transaction source id different from configured one", "transaction", t, "config", config)
+			continue
+		}
+		// Fees are created from the same account and currency, they must not be charged a fee in turn
+		if slices.Contains(t.Tags, feeTag) {
+			a.Logger.Debug("Transaction is a fee created by the webhook", "transaction", t)
+			continue
+		}
+		if !config.IsForeign(t) {
+			a.Logger.Debug("Transaction not made in a foreign currency", "transaction", t)
+			continue
+		}
+		transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
 		if err != nil {
-			a.serverError(w, r, err)
-			return
+			return "", fmt.Errorf("invalid transaction amount %q", t.Amount)
+		}
+		fee := math.Abs(transactionAmount)*config.Percentage/100 + config.FixedAmount
+		if fee <= math.Pow10(-t.CurrencyDecimalPlaces) {
+			a.Logger.Debug("No need to create new transaction: fee lesser than zero", "fee", fee)
+			continue
+		}
+		created, err := a.createForeignFeeTransaction(ctx, &t, fee, config)
+		if err != nil {
+			return "", err
+		}
+
+		err = a.linkCreatedTransaction(ctx, config.LinkTypeId, t.TransactionJournalID, created)
+		if err != nil {
+			return "", err
+		}
+		count++
+	}
+
+	return fmt.Sprintf("created %d fee transactions", count), nil
+}
+
+// piggyBank will add or remove money from a piggy bank with an amount computed from the transactions
+// triggering the webhook, without exceeding the piggy bank target amount.
+func (a *Application) piggyBank(ctx context.Context, msg firefly.WebhookMessage, config firefly.PiggyBankConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	amount := 0.0
+	for _, t := range content.Transactions {
+		sourceID := t.SourceID
+		// If it's a deposit, the source id is the transaction destination id
+		if config.Type == firefly.DEPOSIT {
+			sourceID = t.DestinationID
+		}
+		if sourceID != config.SourceAccountId {
+			a.Logger.Debug("Transaction source id different from configured one", "transaction", t, "config", config)
+			continue
+		}
+		if config.SourceMustHaveTag != "" && !slices.Contains(t.Tags, config.SourceMustHaveTag) {
+			continue
+		}
+		transactionAmount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid transaction amount %q", t.Amount)
+		}
+		switch {
+		case config.FixedAmount != nil:
+			amount += *config.FixedAmount
+		case config.ModuloAmount != nil:
+			if remainder := math.Mod(transactionAmount, *config.ModuloAmount); remainder > 0 {
+				amount += *config.ModuloAmount - remainder
+			}
+		case config.Percentage != nil:
+			amount += transactionAmount * *config.Percentage / 100
+		}
+	}
+
+	if amount <= 0 {
+		a.Logger.Debug("No transaction matching the configuration", "config", config)
+		return "no transaction matching the configuration", nil
+	}
+
+	// The new amount is computed from the current one, so webhooks updating the same piggy bank run one at a time
+	unlock := a.lockPiggyBank(config.PiggyBankID)
+	defer unlock()
+	piggyBank, err := a.FireflyClient.GetPiggyBankContext(ctx, config.PiggyBankID)
+	if err != nil {
+		return "", err
+	}
+	attributes := piggyBank.Data.Attributes
+	zeroWithDelta := math.Pow10(-attributes.CurrencyDecimalPlaces)
+	if amount <= zeroWithDelta {
+		a.Logger.Debug("No need to update the piggy bank: amount lesser than zero", "amount", amount)
+		return "amount too small", nil
+	}
+
+	currentAmount, err := strconv.ParseFloat(strings.TrimSpace(attributes.CurrentAmount), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid piggy bank current amount %q", attributes.CurrentAmount)
+	}
+	var newAmount float64
+	if config.Remove {
+		newAmount = math.Max(currentAmount-amount, 0)
+	} else {
+		newAmount = currentAmount + amount
+		if attributes.TargetAmount != nil {
+			targetAmount, err2 := strconv.ParseFloat(strings.TrimSpace(*attributes.TargetAmount), 64)
+			if err2 != nil {
+				return "", fmt.Errorf("invalid piggy bank target amount %q", *attributes.TargetAmount)
+			}
+			if targetAmount > 0 && currentAmount >= targetAmount-zeroWithDelta {
+				a.Logger.Info("Piggy bank target already reached", "piggy bank", attributes.Name, "target", targetAmount)
+				return "piggy bank target already reached", nil
+			}
+			if targetAmount > 0 && newAmount >= targetAmount-zeroWithDelta {
+				newAmount = targetAmount
+				a.Logger.Info("Piggy bank target reached", "piggy bank", attributes.Name, "target", targetAmount)
+			}
+		}
+	}
+	if math.Abs(newAmount-currentAmount) <= zeroWithDelta {
+		a.Logger.Debug("No need to update the piggy bank: amount unchanged", "amount", currentAmount)
+		return "piggy bank amount unchanged", nil
+	}
+
+	a.Logger.Debug("Updating piggy bank amount", "piggy bank", attributes.Name, "from", currentAmount, "to", newAmount)
+	formattedAmount := fmt.Sprintf("%.[2]*[1]f", newAmount, attributes.CurrencyDecimalPlaces)
+	_, err = a.FireflyClient.SetPiggyBankAmountContext(ctx, config.PiggyBankID, formattedAmount)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("piggy bank %s updated to %s", attributes.Name, formattedAmount), nil
+}
+
+// balanceAlert will send a notification when the balance of an account crosses the configured thresholds.
+// The last known state is stored so the notification is sent only once per crossing.
+func (a *Application) balanceAlert(ctx context.Context, msg firefly.WebhookMessage, config firefly.BalanceAlertConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageAccounts)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	notified := 0
+	for _, account := range content {
+		if account.ID != config.AccountId {
+			continue
+		}
+		balance, err := account.Balance()
+		if err != nil {
+			return "", err
+		}
+
+		state := config.State(balance)
+		key := balanceAlertKey(config)
+		previous, _ := a.Store.Get(key)
+		if previous == string(state) {
+			a.Logger.Debug("Account balance state unchanged", "account", account.Name, "state", state)
+			continue
+		}
+		if state == firefly.BALANCE_NORMAL {
+			a.Logger.Debug("Account balance back within thresholds", "account", account.Name, "balance", balance)
+		} else {
+			a.Logger.Debug("Account balance crossed threshold", "account", account.Name, "balance", balance, "state", state)
+			err = a.Notifier.Notify(notify.Notification{
+				Title:   fmt.Sprintf("Balance of %s is %s", account.Name, state),
+				Message: fmt.Sprintf("Current balance of %s is %s %s", account.Name, account.CurrentBalance, account.CurrencyCode),
+				Level:   notify.WARNING,
+			})
+			if err != nil {
+				return "", err
+			}
+			notified++
 		}
+		// The state is stored once notified, so that a failed notification is sent again by the next webhook
+		if err = a.Store.Set(key, string(state)); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("sent %d notifications", notified), nil
+}
+
+// budgetWarning will send a notification when a withdrawal makes its budget reach one of the configured
+// thresholds of the limit for the current period. Each threshold is notified only once per budget limit.
+func (a *Application) budgetWarning(ctx context.Context, msg firefly.WebhookMessage, config firefly.BudgetWarningConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	checked := make(map[string]bool)
+	for _, t := range content.Transactions {
+		if !config.Watches(t) || checked[*t.BudgetID] {
+			continue
+		}
+		checked[*t.BudgetID] = true
+
+		limits, err := a.FireflyClient.ListBudgetLimitsContext(ctx, *t.BudgetID, t.Date, t.Date)
+		if err != nil {
+			return "", err
+		}
+		for _, limit := range limits {
+			if limit.Attributes.CurrencyID != t.CurrencyID {
+				continue
+			}
+			err = a.checkBudgetLimit(ctx, &t, limit, config)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return fmt.Sprintf("checked %d budgets", len(checked)), nil
+}
+
+// mirror will keep a copy of the transactions triggering the webhook in another Firefly III instance,
+// creating, updating or deleting it. The id of the copy is stored so the mirror is idempotent.
+func (a *Application) mirror(ctx context.Context, msg firefly.WebhookMessage, config firefly.MirrorConfig) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	client := a.mirrorClient(config)
+	key := mirrorKey(config, content.ID)
+	mirrorID, mirrored := a.Store.Get(key)
+	// Transactions losing the required tag are removed from the mirror as if they were deleted
+	if msg.Trigger == firefly.DESTROY_TRANSACTION || !config.Mirrors(content) {
+		if !mirrored {
+			a.Logger.Debug("Transaction not mirrored, nothing to delete", "id", content.ID)
+			return "transaction not mirrored", nil
+		}
+		a.Logger.Debug("Deleting mirrored transaction", "id", content.ID, "mirror id", mirrorID)
+		err := client.DeleteTransactionContext(ctx, mirrorID)
+		if err != nil {
+			return "", err
+		}
+		err = a.Store.Delete(key)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("deleted mirrored transaction %s", mirrorID), nil
+	}
+
+	transactions := make([]models.Transaction, 0, len(content.Transactions))
+	for _, t := range content.Transactions {
+		transactions = append(transactions, mirrorTransaction(&t, config))
+	}
+
+	if mirrored {
+		a.Logger.Debug("Updating mirrored transaction", "id", content.ID, "mirror id", mirrorID)
+		id, err := strconv.Atoi(mirrorID)
+		if err != nil {
+			return "", err
+		}
+		// Webhooks of the mirror instance are not fired to avoid loops between instances
+		_, err = client.UpdateTransactionContext(ctx, id, &models.UpdateTransactionRequest{
+			ApplyRules:   true,
+			FireWebhooks: false,
+			Transactions: transactions,
+		})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("updated mirrored transaction %s", mirrorID), nil
+	}
+
+	a.Logger.Debug("Creating mirrored transaction", "id", content.ID)
+	created, err := client.CreateTransactionContext(ctx, &models.StoreTransactionRequest{
+		ApplyRules:           true,
+		ErrorIfDuplicateHash: true,
+		FireWebhooks:         false,
+		Transactions:         transactions,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = a.Store.Set(key, created.Data.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("created mirrored transaction %s", created.Data.ID), nil
+}
+
+// notesCommands will run the commands found in the notes of the transactions, e.g. "!cashback 2%",
+// removing or annotating them in the notes once processed.
+// Commands look up the values they need, e.g. the split ticket configuration, in fireflyConfig.
+func (a *Application) notesCommands(
+	ctx context.Context,
+	fireflyConfig *firefly.Config,
+	msg firefly.WebhookMessage,
+	config firefly.NotesCommandsConfig,
+) (string, error) {
+	content, ok := msg.Content.(firefly.WebhookMessageTransaction)
+	if !ok {
+		return "", errInvalidContent
+	}
+
+	// The whole group is sent back to Firefly, otherwise splits without commands would be removed
+	toUpdate := make([]models.Transaction, len(content.Transactions))
+	for i, t := range content.Transactions {
+		err := copier.Copy(&toUpdate[i], &t)
+		if err != nil {
+			return "", err
+		}
+		if !firefly.HasCommands(t.Notes) {
+			continue
+		}
+		a.runCommands(ctx, fireflyConfig, msg, config, &t, &toUpdate[i])
+	}
+
+	a.Logger.Debug("Updating transaction notes", "id", content.ID)
+	_, err := a.FireflyClient.UpdateTransactionContext(ctx, content.ID, &models.UpdateTransactionRequest{
+		ApplyRules:   true,
+		FireWebhooks: true,
+		Transactions: toUpdate,
+	})
+	if err != nil {
+		return "", err
 	}
 
-	a.Logger.Debug("Webhook completed successfully")
-	a.clientResponse(w, r, http.StatusNoContent)
+	return "transaction notes updated", nil
 }
@@ -0,0 +1,456 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/notify"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+)
+
+// fireflyServer fakes a Firefly III instance, recording the requests it receives.
+type fireflyServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+// newFireflyServer starts a fake Firefly III instance answering with the handler, after recording each request
+// as its method, URI and body.
+func newFireflyServer(t *testing.T, handler http.HandlerFunc) *fireflyServer {
+	t.Helper()
+	s := &fireflyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		s.mu.Lock()
+		s.requests = append(s.requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+		s.mu.Unlock()
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Requests returns the requests received so far.
+func (s *fireflyServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// notifications records the notifications sent, failing with err when set.
+type notifications struct {
+	mu   sync.Mutex
+	sent []notify.Notification
+	err  error
+}
+
+func (n *notifications) Notify(notification notify.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+
+	return nil
+}
+
+// newTestApplication creates an application reaching the given Firefly III instance, with the configuration
+// decoded from JSON.
+func newTestApplication(t *testing.T, server *fireflyServer, config string) *Application {
+	t.Helper()
+	s, err := store.Open("")
+	require.NoError(t, err)
+	a := &Application{
+		FireflyClient: firefly.NewFirefly(
+			server.URL,
+			firefly.WithApiKey("key"),
+			firefly.WithRetryPolicy(firefly.RetryPolicy{MaxAttempts: 1}),
+		),
+		Logger:   slog.New(slog.DiscardHandler),
+		Notifier: &notifications{},
+		Store:    s,
+	}
+	fireflyConfig, err := firefly.ParseConfig([]byte(config))
+	require.NoError(t, err)
+	a.FireflyConfig.Store(fireflyConfig)
+
+	return a
+}
+
+// sendWebhook sends a webhook message with the given content, signed with the secret, to the handler.
+func sendWebhook(
+	t *testing.T,
+	handler http.Handler,
+	trigger firefly.WebhookTrigger,
+	response firefly.WebhookResponse,
+	content any,
+	secret string,
+) []ActionResult {
+	t.Helper()
+	_, results := sendWebhookStatus(t, handler, trigger, response, content, secret)
+
+	return results
+}
+
+// sendWebhookStatus is like sendWebhook, returning the status of the response as well.
+func sendWebhookStatus(
+	t *testing.T,
+	handler http.Handler,
+	trigger firefly.WebhookTrigger,
+	response firefly.WebhookResponse,
+	content any,
+	secret string,
+) (int, []ActionResult) {
+	t.Helper()
+	rawContent, err := json.Marshal(content)
+	require.NoError(t, err)
+	body, err := json.Marshal(firefly.WebhookMessage{RawContent: rawContent, Trigger: trigger, Response: response})
+	require.NoError(t, err)
+	mac := hmac.New(sha3.New256, []byte(secret))
+	_, err = mac.Write([]byte("1610738765." + string(body)))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/webhook", strings.NewReader(string(body)))
+	r.Header.Set("Signature", fmt.Sprintf("t=1610738765,v1=%x", mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var results []ActionResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results), w.Body.String())
+
+	return w.Code, results
+}
+
+// createdTransaction answers the creation of a transaction with a group of a single split.
+func createdTransaction(w http.ResponseWriter, id string, journalID string) {
+	var res models.UpsertTransactionResponse
+	res.Data.ID = id
+	res.Data.Attributes.Transactions = []models.TransactionResponse{{TransactionJournalID: journalID}}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func TestForeignFee(t *testing.T) {
+	server := newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/transactions" {
+			createdTransaction(w, "20", "21")
+		}
+	})
+	a := newTestApplication(t, server, `{"foreign_fee": [{
+  "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+  "title": "Fee", "link_type_id": "1", "source_account_id": "1", "destination_account_id": "2",
+  "category_id": "3", "currencies": ["EUR"], "fixed_amount": 1
+}]}`)
+	handler := a.webhook(firefly.ForeignFee)
+	transaction := models.Transaction{
+		Type:                  string(firefly.WITHDRAWAL),
+		Amount:                "10.00",
+		SourceID:              "1",
+		CurrencyID:            "1",
+		CurrencyCode:          "EUR",
+		CurrencyDecimalPlaces: 2,
+		TransactionJournalID:  "11",
+	}
+
+	results := sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_TRANSACTIONS,
+		firefly.WebhookMessageTransaction{ID: 10, Transactions: []models.Transaction{transaction}}, "abc")
+	assert.Equal(t, []ActionResult{{Config: "foreign_fee[0]", Status: ACTION_DONE, Message: "created 1 fee transactions"}}, results)
+	requests := server.Requests()
+	require.Len(t, requests, 2)
+	assert.Contains(t, requests[0], `"tags":["Webhook: foreign_fee"]`)
+
+	// The fee is in the same account and currency, Firefly sends it to the webhook as well
+	fee := transaction
+	fee.Amount, fee.Tags, fee.TransactionJournalID = "1.00", []string{"Webhook: foreign_fee"}, "21"
+	results = sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_TRANSACTIONS,
+		firefly.WebhookMessageTransaction{ID: 20, Transactions: []models.Transaction{fee}}, "abc")
+	assert.Equal(t, []ActionResult{{Config: "foreign_fee[0]", Status: ACTION_DONE, Message: "created 0 fee transactions"}}, results)
+	assert.Len(t, server.Requests(), 2)
+}
+
+// piggyBankServer fakes the piggy banks of Firefly III, keeping the current amount of each one.
+func piggyBankServer(t *testing.T, amounts map[string]float64, target float64) *fireflyServer {
+	var mu sync.Mutex
+	return newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/piggy-banks/")
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut {
+			var req models.UpdatePiggyBankRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			amount, err := strconv.ParseFloat(req.CurrentAmount, 64)
+			assert.NoError(t, err)
+			amounts[id] = amount
+		}
+		fmt.Fprintf(w, `{"data": {"type": "piggy_banks", "id": "%s", "attributes": {"name": "Holidays",
+			"current_amount": "%.2f", "target_amount": "%.2f", "currency_decimal_places": 2}}}`, id, amounts[id], target)
+	})
+}
+
+func TestPiggyBank(t *testing.T) {
+	config := `{"piggy_bank": [{
+  "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+  "piggy_bank_id": "2", "source_account_id": "1", "modulo_amount": 5
+}]}`
+	transaction := func(amount string) firefly.WebhookMessageTransaction {
+		return firefly.WebhookMessageTransaction{ID: 10, Transactions: []models.Transaction{
+			{Type: string(firefly.WITHDRAWAL), Amount: amount, SourceID: "1", CurrencyDecimalPlaces: 2},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		current  float64
+		amount   string
+		expected float64
+		message  string
+	}{
+		{
+			name:     "rounded up to the modulo",
+			current:  100,
+			amount:   "12.50",
+			expected: 102.5,
+			message:  "piggy bank Holidays updated to 102.50",
+		},
+		{
+			name:     "capped at the target",
+			current:  199,
+			amount:   "12.00",
+			expected: 200,
+			message:  "piggy bank Holidays updated to 200.00",
+		},
+		{
+			name:     "target already reached",
+			current:  200,
+			amount:   "12.00",
+			expected: 200,
+			message:  "piggy bank target already reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amounts := map[string]float64{"2": tt.current}
+			a := newTestApplication(t, piggyBankServer(t, amounts, 200), config)
+
+			results := sendWebhook(t, a.webhook(firefly.PiggyBank), firefly.STORE_TRANSACTION,
+				firefly.RESPONSE_TRANSACTIONS, transaction(tt.amount), "abc")
+			assert.Equal(t, []ActionResult{{Config: "piggy_bank[0]", Status: ACTION_DONE, Message: tt.message}}, results)
+			assert.Equal(t, tt.expected, amounts["2"])
+		})
+	}
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		amounts := map[string]float64{"2": 0}
+		a := newTestApplication(t, piggyBankServer(t, amounts, 1000), config)
+		handler := a.webhook(firefly.PiggyBank)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_TRANSACTIONS, transaction("2.00"), "abc")
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, 30.0, amounts["2"])
+	})
+}
+
+func TestWebhookStatus(t *testing.T) {
+	config := `{"piggy_bank": [
+  {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+   "piggy_bank_id": "2", "source_account_id": "1", "modulo_amount": 5},
+  {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+   "piggy_bank_id": "3", "source_account_id": "1", "modulo_amount": 5}
+]}`
+	content := firefly.WebhookMessageTransaction{ID: 10, Transactions: []models.Transaction{
+		{Type: string(firefly.WITHDRAWAL), Amount: "2.00", SourceID: "1", CurrencyDecimalPlaces: 2},
+	}}
+
+	tests := []struct {
+		name     string
+		failing  []string
+		status   int
+		statuses []ActionStatus
+	}{
+		{
+			name:     "every value completed",
+			status:   http.StatusOK,
+			statuses: []ActionStatus{ACTION_DONE, ACTION_DONE},
+		},
+		{
+			// Firefly must not send the message again, the first piggy bank would be updated twice
+			name:     "some values failed",
+			failing:  []string{"3"},
+			status:   http.StatusMultiStatus,
+			statuses: []ActionStatus{ACTION_DONE, ACTION_FAILED},
+		},
+		{
+			name:     "every value failed",
+			failing:  []string{"2", "3"},
+			status:   http.StatusInternalServerError,
+			statuses: []ActionStatus{ACTION_FAILED, ACTION_FAILED},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amounts := map[string]float64{"2": 0, "3": 0}
+			piggyBanks := piggyBankServer(t, amounts, 100)
+			server := newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if slices.Contains(tt.failing, strings.TrimPrefix(r.URL.Path, "/api/v1/piggy-banks/")) {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				piggyBanks.Config.Handler.ServeHTTP(w, r)
+			})
+			a := newTestApplication(t, server, config)
+
+			status, results := sendWebhookStatus(t, a.webhook(), firefly.STORE_TRANSACTION,
+				firefly.RESPONSE_TRANSACTIONS, content, "abc")
+			assert.Equal(t, tt.status, status)
+			statuses := make([]ActionStatus, 0, len(results))
+			for _, result := range results {
+				statuses = append(statuses, result.Status)
+			}
+			assert.Equal(t, tt.statuses, statuses)
+		})
+	}
+}
+
+func TestBalanceAlert(t *testing.T) {
+	a := newTestApplication(t, newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {}), `{"balance_alert": [{
+  "trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 100
+}]}`)
+	sink := &notifications{err: errors.New("sink unavailable")}
+	a.Notifier = sink
+	handler := a.webhook(firefly.BalanceAlert)
+	accounts := firefly.WebhookMessageAccounts{{ID: "1", Name: "Checking", CurrentBalance: "50.00", CurrencyCode: "EUR"}}
+
+	results := sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_ACCOUNTS, accounts, "abc")
+	assert.Equal(t, []ActionResult{{Config: "balance_alert[0]", Status: ACTION_FAILED, Message: "sink unavailable"}}, results)
+	assert.Empty(t, sink.sent)
+
+	// The crossing wasn't notified, so the next webhook notifies it
+	sink.err = nil
+	results = sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_ACCOUNTS, accounts, "abc")
+	assert.Equal(t, []ActionResult{{Config: "balance_alert[0]", Status: ACTION_DONE, Message: "sent 1 notifications"}}, results)
+	assert.Equal(t, []notify.Notification{{
+		Title:   "Balance of Checking is low",
+		Message: "Current balance of Checking is 50.00 EUR",
+		Level:   notify.WARNING,
+	}}, sink.sent)
+
+	results = sendWebhook(t, handler, firefly.STORE_TRANSACTION, firefly.RESPONSE_ACCOUNTS, accounts, "abc")
+	assert.Equal(t, []ActionResult{{Config: "balance_alert[0]", Status: ACTION_DONE, Message: "sent 0 notifications"}}, results)
+	assert.Len(t, sink.sent, 1)
+}
+
+func TestMirror(t *testing.T) {
+	mirror := newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			createdTransaction(w, "30", "31")
+		}
+	})
+	config := fmt.Sprintf(`{"mirror": [
+  {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "base_url": %[1]q, "api_key": "key"},
+  {"trigger": "UPDATE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def", "base_url": %[1]q, "api_key": "key"},
+  {"trigger": "DESTROY_TRANSACTION", "response": "TRANSACTIONS", "secret": "ghi", "base_url": %[1]q, "api_key": "key"}
+]}`, mirror.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	// restart creates the application again, reading the state persisted by the previous one
+	restart := func() http.Handler {
+		a := newTestApplication(t, newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {}), config)
+		var err error
+		a.Config.StateFile = stateFile
+		a.Store, err = store.Open(stateFile)
+		require.NoError(t, err)
+		return a.webhook(firefly.Mirror)
+	}
+	content := firefly.WebhookMessageTransaction{ID: 10, Transactions: []models.Transaction{
+		{Type: string(firefly.WITHDRAWAL), Amount: "10.00", Description: "Shopping"},
+	}}
+
+	results := sendWebhook(t, restart(), firefly.STORE_TRANSACTION, firefly.RESPONSE_TRANSACTIONS, content, "abc")
+	assert.Equal(t, []ActionResult{{Config: "mirror[0]", Status: ACTION_DONE, Message: "created mirrored transaction 30"}}, results)
+	results = sendWebhook(t, restart(), firefly.UPDATE_TRANSACTION, firefly.RESPONSE_TRANSACTIONS, content, "def")
+	assert.Equal(t, []ActionResult{{Config: "mirror[1]", Status: ACTION_DONE, Message: "updated mirrored transaction 30"}}, results)
+	results = sendWebhook(t, restart(), firefly.DESTROY_TRANSACTION, firefly.RESPONSE_TRANSACTIONS, content, "ghi")
+	assert.Equal(t, []ActionResult{{Config: "mirror[2]", Status: ACTION_DONE, Message: "deleted mirrored transaction 30"}}, results)
+
+	requests := mirror.Requests()
+	require.Len(t, requests, 3)
+	assert.Contains(t, requests[0], "POST /api/v1/transactions ")
+	assert.Contains(t, requests[1], "PUT /api/v1/transactions/30 ")
+	assert.Equal(t, "DELETE /api/v1/transactions/30", requests[2])
+}
+
+func TestNotesCommandsConfigReloaded(t *testing.T) {
+	config := func(categoryID string) string {
+		return `{
+  "piggy_bank": [{"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+    "piggy_bank_id": "2", "source_account_id": "1", "fixed_amount": 1, "priority": 10}],
+  "notes_commands": [{"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc"}],
+  "cashback": [{"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+    "title": "Cashback", "source_must_have_tag": "cashback", "link_type_id": "1", "source_account_id": "1",
+    "deposit_source_account_id": "4", "destination_account_id": "5", "amount": 1, "category_id": "` + categoryID + `",
+    "destination_currency_id": "1", "destination_currency_decimal_places": 2}]
+}`
+	}
+	reloaded, err := firefly.ParseConfig([]byte(config("99")))
+	require.NoError(t, err)
+	var a *Application
+	server := newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/piggy-banks/"):
+			// The configuration is reloaded while the webhook runs its first value
+			a.FireflyConfig.Store(reloaded)
+			fmt.Fprint(w, `{"data": {"id": "2", "attributes": {"name": "Holidays", "current_amount": "0.00",
+				"currency_decimal_places": 2}}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/transactions":
+			createdTransaction(w, "20", "21")
+		}
+	})
+	a = newTestApplication(t, server, config("3"))
+	notes := "!cashback 10%"
+	content := firefly.WebhookMessageTransaction{ID: 10, Transactions: []models.Transaction{{
+		Type: string(firefly.WITHDRAWAL), Amount: "50.00", SourceID: "1", CurrencyDecimalPlaces: 2, Notes: &notes,
+	}}}
+
+	results := sendWebhook(t, a.webhook(firefly.PiggyBank, firefly.NotesCommands), firefly.STORE_TRANSACTION,
+		firefly.RESPONSE_TRANSACTIONS, content, "abc")
+	assert.Equal(t, []ActionResult{
+		{Config: "piggy_bank[0]", Status: ACTION_DONE, Message: "piggy bank Holidays updated to 1.00"},
+		{Config: "notes_commands[0]", Status: ACTION_DONE, Message: "transaction notes updated"},
+	}, results)
+	var created []string
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "POST /api/v1/transactions ") {
+			created = append(created, request)
+		}
+	}
+	require.Len(t, created, 1)
+	assert.Contains(t, created[0], `"category_id":"3"`)
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce is the time to wait after the last change to the configuration file before reloading it,
+// editors and config map updates usually write the file in several steps.
+const reloadDebounce = 500 * time.Millisecond
+
+// LoadFireflyConfig loads the configuration file, resolving the names of the referenced resources into ids.
+func (a *Application) LoadFireflyConfig() (*firefly.Config, error) {
+	resolver := firefly.NewNameResolver(a.FireflyClient)
+	config, err := firefly.LoadConfig(a.Config.FireflyConfigFile, a.Config.LoadOptions(resolver)...)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.Config.CheckFireflyConfig(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// ReloadConfig loads the configuration file again, replacing the current configuration only if the new one is valid.
+func (a *Application) ReloadConfig() error {
+	config, err := a.LoadFireflyConfig()
+	if err != nil {
+		a.Logger.Error("Invalid configuration, keeping the previous one", "file", a.Config.FireflyConfigFile, "error", err)
+		return err
+	}
+
+	a.FireflyConfig.Store(config)
+	a.Logger.Info("Configuration reloaded", "file", a.Config.FireflyConfigFile)
+	return nil
+}
+
+// WatchConfig reloads the configuration when its file changes or when a SIGHUP is received,
+// until the context is cancelled.
+func (a *Application) WatchConfig(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func(watcher *fsnotify.Watcher) {
+		_ = watcher.Close()
+	}(watcher)
+
+	// Watch the directory, files replaced by renaming them (editors, Kubernetes config maps) would be lost otherwise
+	path, err := filepath.Abs(a.Config.FireflyConfigFile)
+	if err != nil {
+		return err
+	}
+	matches, dir, err := configWatch(path)
+	if err != nil {
+		return err
+	}
+	err = watcher.Add(dir)
+	if err != nil {
+		return err
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hangup:
+			a.Logger.Info("Received SIGHUP, reloading configuration")
+			_ = a.ReloadConfig()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !matches(filepath.Clean(event.Name)) && !isConfigMapSwap(event) {
+				continue
+			}
+			a.Logger.Debug("Configuration file changed", "event", event)
+			debounce.Reset(reloadDebounce)
+		case <-debounce.C:
+			_ = a.ReloadConfig()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			a.Logger.Error("Failed watching configuration file", "error", err)
+		}
+	}
+}
+
+// configWatch returns the directory to watch for the configuration at the given absolute path, a file, a
+// directory or a glob pattern, and the function checking if a changed file is part of the configuration.
+func configWatch(path string) (func(string) bool, string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		return func(name string) bool {
+			ok, _ := filepath.Match(path, name)
+			return ok
+		}, filepath.Dir(path), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		return func(name string) bool {
+			return filepath.Dir(name) == path && firefly.IsConfigFile(name)
+		}, path, nil
+	}
+
+	return func(name string) bool { return name == path }, filepath.Dir(path), nil
+}
+
+// isConfigMapSwap checks if the event is Kubernetes replacing the data directory of a mounted config map.
+func isConfigMapSwap(event fsnotify.Event) bool {
+	return filepath.Base(event.Name) == "..data" && event.Has(fsnotify.Create)
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	alert := func(threshold string) string {
+		return `{"balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc",
+  "account_id": "1", "low_threshold": ` + threshold + `}]}`
+	}
+	a := newTestApplication(t, newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {}), alert("10"))
+	a.Config.FireflyConfigFile = file
+	lowThreshold := func() float64 {
+		return *(*a.FireflyConfig.Load())[firefly.BalanceAlert][0].(firefly.BalanceAlertConfig).LowThreshold
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		err      string
+		expected float64
+	}{
+		{
+			name:     "valid configuration swapped in",
+			content:  alert("20"),
+			expected: 20,
+		},
+		{
+			name:     "configuration that can't be decoded",
+			content:  `{"balance_alert": [`,
+			err:      "config.json:1:19: unexpected end of JSON input",
+			expected: 20,
+		},
+		{
+			name:     "configuration decoded but semantically invalid",
+			content:  `{"balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1"}]}`,
+			err:      "config.json:1:20: balance_alert[0].low_threshold: either low_threshold or high_threshold must be set",
+			expected: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(file, []byte(tt.content), 0o644))
+			err := a.ReloadConfig()
+			if tt.err != "" {
+				var configErrors firefly.ConfigErrors
+				require.ErrorAs(t, err, &configErrors)
+				assert.Contains(t, err.Error(), tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, lowThreshold())
+		})
+	}
+}
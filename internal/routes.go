@@ -3,6 +3,7 @@ package internal
 import (
 	"net/http"
 
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
 	"github.com/justinas/alice"
 )
 
@@ -17,9 +18,23 @@ func (a *Application) Routes(config Config) http.Handler {
 		a.contentTypeHeader,
 	)
 
-	mux.Handle("/api/v1/webhook/split-ticket", protected.ThenFunc(a.splitTicket))
-	mux.Handle("/api/v1/webhook/cashback", protected.ThenFunc(a.cashback))
-	mux.Handle("/api/v1/webhook/transfer", protected.ThenFunc(a.transfer))
+	// Runs the values of every action applying to the message, so a single webhook can trigger many actions
+	mux.Handle("/api/v1/webhook", protected.ThenFunc(a.webhook()))
+	mux.Handle("/api/v1/webhook/split-ticket", protected.ThenFunc(a.webhook(firefly.SplitTicket)))
+	mux.Handle("/api/v1/webhook/cashback", protected.ThenFunc(a.webhook(firefly.Cashback)))
+	mux.Handle("/api/v1/webhook/transfer", protected.ThenFunc(a.webhook(firefly.Transfer)))
+	mux.Handle("/api/v1/webhook/foreign-fee", protected.ThenFunc(a.webhook(firefly.ForeignFee)))
+	mux.Handle("/api/v1/webhook/piggy-bank", protected.ThenFunc(a.webhook(firefly.PiggyBank)))
+	mux.Handle("/api/v1/webhook/balance-alert", protected.ThenFunc(a.webhook(firefly.BalanceAlert)))
+	mux.Handle("/api/v1/webhook/budget-warning", protected.ThenFunc(a.webhook(firefly.BudgetWarning)))
+	mux.Handle("/api/v1/webhook/mirror", protected.ThenFunc(a.webhook(firefly.Mirror)))
+	mux.Handle("/api/v1/webhook/notes-commands", protected.ThenFunc(a.webhook(firefly.NotesCommands)))
+
+	admin := alice.New(a.requireAdmin)
+	mux.Handle("GET /api/v1/admin/config", admin.ThenFunc(a.listConfig))
+	mux.Handle("PUT /api/v1/admin/config", admin.ThenFunc(a.uploadConfig))
+	mux.Handle("POST /api/v1/admin/config/{type}/{index}/enable", admin.Then(a.setConfigEnabled(true)))
+	mux.Handle("POST /api/v1/admin/config/{type}/{index}/disable", admin.Then(a.setConfigEnabled(false)))
 
 	return protected.Then(mux)
 }
@@ -1,15 +1,20 @@
 package internal
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/notify"
 	"github.com/akyrey/firefly-iii-webhooks/pkg/utils"
 	"github.com/jinzhu/copier"
 )
@@ -33,6 +38,7 @@ func (a *Application) parseRequestMessage(r *http.Request) (body []byte, webhook
 
 // updateSplitTransaction will update the transaction with the new amount and foreign amount.
 func (a *Application) updateSplitTransaction(
+	ctx context.Context,
 	t *models.Transaction,
 	contentID int,
 	division float64,
@@ -53,7 +59,8 @@ func (a *Application) updateSplitTransaction(
 	tToUpdate.Tags = append(tToUpdate.Tags, fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.SplitTicket))
 	tToUpdate.TransactionJournalID = ""
 	a.Logger.Debug("Updating transaction amount, foreign amount and tags", "contentID", contentID, "transaction", tToUpdate)
-	return a.FireflyClient.UpdateTransaction(
+	return a.FireflyClient.UpdateTransactionContext(
+		ctx,
 		contentID,
 		&models.UpdateTransactionRequest{
 			ApplyRules:   true,
@@ -62,31 +69,62 @@ func (a *Application) updateSplitTransaction(
 		})
 }
 
+// currency returns the currency of the transactions created in the given account. It's the one of the account
+// unless a currency id is configured, and its decimal places are the ones of the currency unless configured as well.
+func (a *Application) currency(ctx context.Context, accountID string, currencyID string, decimalPlaces *int) (firefly.CurrencyInfo, error) {
+	if currencyID != "" && decimalPlaces != nil {
+		return firefly.CurrencyInfo{ID: currencyID, DecimalPlaces: *decimalPlaces}, nil
+	}
+
+	var currency firefly.CurrencyInfo
+	var err error
+	if currencyID != "" {
+		currency, err = a.FireflyClient.CurrencyContext(ctx, currencyID)
+	} else {
+		currency, err = a.FireflyClient.AccountCurrencyContext(ctx, accountID)
+	}
+	if err != nil {
+		return currency, fmt.Errorf("fetching currency of account %s: %w", accountID, err)
+	}
+	if decimalPlaces != nil {
+		currency.DecimalPlaces = *decimalPlaces
+	}
+
+	return currency, nil
+}
+
 // createSplitTransaction will create a new transaction with the remaining amount.
 func (a *Application) createSplitTransaction(
+	ctx context.Context,
 	t *models.Transaction,
 	modulo float64,
-	currencyDecimalPlaces int,
-	accountID string,
-	currencyID string,
+	config firefly.SplitTicketConfig,
 ) (*models.UpsertTransactionResponse, error) {
-	moduloAmount := fmt.Sprintf("%.[2]*[1]f", modulo, currencyDecimalPlaces)
+	currency, err := a.currency(ctx, config.DestinationAccountId, config.DestinationCurrencyId, config.DestinationCurrencyDecimalPlaces)
+	if err != nil {
+		return nil, err
+	}
+	moduloAmount := fmt.Sprintf("%.[2]*[1]f", modulo, currency.DecimalPlaces)
+	description, notes, err := transactionTexts(t, moduloAmount, config, config.Title, config.Notes, t.Description)
+	if err != nil {
+		return nil, err
+	}
 	tToCreate := models.Transaction{
 		Amount:        moduloAmount,
-		SourceID:      accountID,
-		CurrencyID:    currencyID,
+		SourceID:      config.DestinationAccountId,
+		CurrencyID:    currency.ID,
 		DestinationID: t.DestinationID,
 		User:          t.User,
 		Type:          string(firefly.WITHDRAWAL),
-		Description:   t.Description,
+		Description:   description,
 		BudgetID:      t.BudgetID,
 		CategoryID:    t.CategoryID,
 		Tags:          append(t.Tags, fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.SplitTicket)),
 		Date:          t.Date.Add(time.Second),
-		Notes:         t.Notes,
+		Notes:         notes,
 	}
 	a.Logger.Debug("Creating transaction", "transaction", tToCreate)
-	return a.FireflyClient.CreateTransaction(&models.StoreTransactionRequest{
+	return a.FireflyClient.CreateTransactionContext(ctx, &models.StoreTransactionRequest{
 		ApplyRules:           true,
 		ErrorIfDuplicateHash: true,
 		FireWebhooks:         true,
@@ -94,12 +132,47 @@ func (a *Application) createSplitTransaction(
 	})
 }
 
+// transactionTexts renders the description and notes of a transaction created from t with the given amount.
+// Without templates they default to the given description and to the notes of t.
+func transactionTexts(
+	t *models.Transaction,
+	amount string,
+	config firefly.ConfigValue,
+	title *firefly.Template,
+	notes *firefly.Template,
+	description string,
+) (string, *string, error) {
+	data := firefly.TemplateData{Source: *t, Amount: amount, Config: config, Date: t.Date}
+	description, err := title.Render(data, description)
+	if err != nil {
+		return "", nil, fmt.Errorf("rendering title: %w", err)
+	}
+	if notes == nil {
+		return description, t.Notes, nil
+	}
+	renderedNotes, err := notes.Render(data, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("rendering notes: %w", err)
+	}
+
+	return description, &renderedNotes, nil
+}
+
 // createCashbackTransaction will create a new transaction with the cashback amount.
 func (a *Application) createCashbackTransaction(
+	ctx context.Context,
 	t *models.Transaction,
 	config firefly.CashbackConfig,
 ) (*models.UpsertTransactionResponse, error) {
-	cashbackAmount := fmt.Sprintf("%.[2]*[1]f", config.Amount, config.DestinationCurrencyDecimalPlaces)
+	currency, err := a.currency(ctx, config.DestinationAccountId, config.DestinationCurrencyId, config.DestinationCurrencyDecimalPlaces)
+	if err != nil {
+		return nil, err
+	}
+	cashbackAmount := fmt.Sprintf("%.[2]*[1]f", config.Amount, currency.DecimalPlaces)
+	description, notes, err := transactionTexts(t, cashbackAmount, config, &config.Title, config.Notes, "")
+	if err != nil {
+		return nil, err
+	}
 	// We need to filter mustHaveTag to avoid creating an infinite loop and previously added webhooks tags.
 	tags := utils.Filter(
 		t.Tags,
@@ -111,19 +184,19 @@ func (a *Application) createCashbackTransaction(
 	tToCreate := models.Transaction{
 		Amount:        cashbackAmount,
 		SourceID:      config.DepositSourceAccountId,
-		CurrencyID:    config.DestinationCurrencyId,
+		CurrencyID:    currency.ID,
 		DestinationID: config.DestinationAccountId,
 		User:          t.User,
 		Type:          string(firefly.DEPOSIT),
-		Description:   config.Title,
+		Description:   description,
 		BudgetID:      t.BudgetID,
 		CategoryID:    &config.CategoryID,
 		Tags:          tags,
 		Date:          t.Date,
-		Notes:         t.Notes,
+		Notes:         notes,
 	}
 	a.Logger.Debug("Creating transaction", "transaction", tToCreate)
-	return a.FireflyClient.CreateTransaction(&models.StoreTransactionRequest{
+	return a.FireflyClient.CreateTransactionContext(ctx, &models.StoreTransactionRequest{
 		ApplyRules:           true,
 		ErrorIfDuplicateHash: true,
 		FireWebhooks:         true,
@@ -133,31 +206,272 @@ func (a *Application) createCashbackTransaction(
 
 // createTransferTransaction will create a new transaction with the cashback amount.
 func (a *Application) createTransferTransaction(
+	ctx context.Context,
 	t *models.Transaction,
 	amount float64,
 	config firefly.TransferConfig,
 ) (*models.UpsertTransactionResponse, error) {
-	transferAmount := fmt.Sprintf("%.[2]*[1]f", amount, config.DestinationCurrencyDecimalPlaces)
+	currency, err := a.currency(ctx, config.DestinationAccountId, config.DestinationCurrencyId, config.DestinationCurrencyDecimalPlaces)
+	if err != nil {
+		return nil, err
+	}
+	transferAmount := fmt.Sprintf("%.[2]*[1]f", amount, currency.DecimalPlaces)
+	description, notes, err := transactionTexts(t, transferAmount, config, &config.Title, config.Notes, "")
+	if err != nil {
+		return nil, err
+	}
 	tags := []string{fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.Transfer)}
 	tToCreate := models.Transaction{
 		Amount:        transferAmount,
 		SourceID:      config.SourceAccountId,
-		CurrencyID:    config.DestinationCurrencyId,
+		CurrencyID:    currency.ID,
 		DestinationID: config.DestinationAccountId,
 		User:          t.User,
 		Type:          string(firefly.TRANSFER),
-		Description:   config.Title,
+		Description:   description,
 		BudgetID:      t.BudgetID,
 		CategoryID:    &config.CategoryID,
 		Tags:          tags,
 		Date:          t.Date,
-		Notes:         t.Notes,
+		Notes:         notes,
 	}
 	a.Logger.Debug("Creating transaction", "transaction", tToCreate)
-	return a.FireflyClient.CreateTransaction(&models.StoreTransactionRequest{
+	return a.FireflyClient.CreateTransactionContext(ctx, &models.StoreTransactionRequest{
 		ApplyRules:           true,
 		ErrorIfDuplicateHash: true,
 		FireWebhooks:         true,
 		Transactions:         []models.Transaction{tToCreate},
 	})
 }
+
+// createForeignFeeTransaction will create a new withdrawal transaction with the foreign currency fee amount.
+func (a *Application) createForeignFeeTransaction(
+	ctx context.Context,
+	t *models.Transaction,
+	fee float64,
+	config firefly.ForeignFeeConfig,
+) (*models.UpsertTransactionResponse, error) {
+	feeAmount := fmt.Sprintf("%.[2]*[1]f", fee, t.CurrencyDecimalPlaces)
+	description, notes, err := transactionTexts(t, feeAmount, config, &config.Title, config.Notes, "")
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.ForeignFee)}
+	tToCreate := models.Transaction{
+		Amount:        feeAmount,
+		SourceID:      t.SourceID,
+		CurrencyID:    t.CurrencyID,
+		DestinationID: config.DestinationAccountId,
+		User:          t.User,
+		Type:          string(firefly.WITHDRAWAL),
+		Description:   description,
+		BudgetID:      t.BudgetID,
+		CategoryID:    &config.CategoryID,
+		Tags:          tags,
+		Date:          t.Date,
+		Notes:         notes,
+	}
+	a.Logger.Debug("Creating transaction", "transaction", tToCreate)
+	return a.FireflyClient.CreateTransactionContext(ctx, &models.StoreTransactionRequest{
+		ApplyRules:           true,
+		ErrorIfDuplicateHash: true,
+		FireWebhooks:         true,
+		Transactions:         []models.Transaction{tToCreate},
+	})
+}
+
+// balanceAlertKey returns the key used to store the last balance state for the given configuration.
+func balanceAlertKey(config firefly.BalanceAlertConfig) string {
+	low, high := "-", "-"
+	if config.LowThreshold != nil {
+		low = strconv.FormatFloat(*config.LowThreshold, 'f', -1, 64)
+	}
+	if config.HighThreshold != nil {
+		high = strconv.FormatFloat(*config.HighThreshold, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%s:%s:%s:%s", firefly.BalanceAlert, config.AccountId, low, high)
+}
+
+// checkBudgetLimit will notify when the percentage used of the budget limit reaches a threshold
+// higher than the last one notified for the same limit.
+func (a *Application) checkBudgetLimit(
+	ctx context.Context,
+	t *models.Transaction,
+	limit models.BudgetLimitData,
+	config firefly.BudgetWarningConfig,
+) error {
+	usage, err := limit.Attributes.Usage()
+	if err != nil {
+		return err
+	}
+	if usage.Amount <= 0 || limit.Attributes.Spent == nil {
+		a.Logger.Debug("Budget limit without amount or spent", "limit", limit)
+		return nil
+	}
+
+	reached := config.ReachedThreshold(usage.Percentage)
+	key := fmt.Sprintf("%s:%s", firefly.BudgetWarning, limit.ID)
+	previous := 0.0
+	if value, ok := a.Store.Get(key); ok {
+		previous, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+	}
+	if reached <= previous {
+		a.Logger.Debug("No new budget threshold reached", "limit", limit.ID, "percentage", usage.Percentage, "notified", previous)
+		return nil
+	}
+
+	budgetName := ""
+	if t.BudgetName != nil {
+		budgetName = *t.BudgetName
+	} else {
+		budget, err2 := a.FireflyClient.GetBudgetContext(ctx, limit.Attributes.BudgetID)
+		if err2 != nil {
+			return err2
+		}
+		budgetName = budget.Data.Attributes.Name
+	}
+
+	a.Logger.Debug("Budget threshold reached", "budget", budgetName, "percentage", usage.Percentage, "threshold", reached)
+	err = a.Notifier.Notify(notify.Notification{
+		Title: fmt.Sprintf("Budget %s reached %.0f%%", budgetName, reached),
+		Message: fmt.Sprintf(
+			"Spent %.[4]*[1]f of %.[4]*[2]f %[3]s (%.1[5]f%%) between %[6]s and %[7]s",
+			usage.Spent,
+			usage.Amount,
+			limit.Attributes.CurrencyCode,
+			limit.Attributes.CurrencyDecimalPlaces,
+			usage.Percentage,
+			limit.Attributes.Start.Format(time.DateOnly),
+			limit.Attributes.End.Format(time.DateOnly),
+		),
+		Level: notify.WARNING,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.Store.Set(key, strconv.FormatFloat(reached, 'f', -1, 64))
+}
+
+// mirrorClient returns the client used to reach the mirror instance of the given configuration.
+func (a *Application) mirrorClient(config firefly.MirrorConfig) *firefly.Firefly {
+	a.mirrorMu.Lock()
+	defer a.mirrorMu.Unlock()
+
+	if a.mirrorClients == nil {
+		a.mirrorClients = make(map[string]*firefly.Firefly)
+	}
+	// The key is hashed so that the api key isn't kept in clear in the cache index
+	apiKey := sha256.Sum256([]byte(config.ApiKey))
+	key := fmt.Sprintf("%s|%x", config.BaseUrl, apiKey)
+	client, ok := a.mirrorClients[key]
+	if !ok {
+		client = firefly.NewFirefly(
+			config.BaseUrl,
+			firefly.WithApiKey(string(config.ApiKey)),
+			firefly.WithTimeout(a.Config.FireflyTimeout),
+			firefly.WithLogger(a.Logger),
+		)
+		a.mirrorClients[key] = client
+	}
+
+	return client
+}
+
+// lockPiggyBank locks the piggy bank with the given id until the returned function is called.
+func (a *Application) lockPiggyBank(id string) func() {
+	a.piggyBankMu.Lock()
+	if a.piggyBankLocks == nil {
+		a.piggyBankLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := a.piggyBankLocks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.piggyBankLocks[id] = lock
+	}
+	a.piggyBankMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// mirrorKey returns the key used to store the id of the mirrored copy of a transaction group.
+func mirrorKey(config firefly.MirrorConfig, groupID int) string {
+	return fmt.Sprintf("%s:%s:%d", firefly.Mirror, config.BaseUrl, groupID)
+}
+
+// mirrorTransaction will map the ids of a transaction to the ones of the mirror instance.
+func mirrorTransaction(t *models.Transaction, config firefly.MirrorConfig) models.Transaction {
+	tags := utils.Filter(
+		t.Tags,
+		func(tag string) bool {
+			return !strings.HasPrefix(tag, firefly.WEBHOOK_TAG_PREFIX)
+		},
+	)
+	tags = append(tags, fmt.Sprintf("%s %s", firefly.WEBHOOK_TAG_PREFIX, firefly.Mirror))
+	tToMirror := models.Transaction{
+		Amount:        t.Amount,
+		ForeignAmount: t.ForeignAmount,
+		CurrencyCode:  t.CurrencyCode,
+		Type:          t.Type,
+		Description:   t.Description,
+		Tags:          tags,
+		Date:          t.Date,
+		Notes:         t.Notes,
+	}
+	if id, ok := config.CurrencyMapping[t.CurrencyID]; ok {
+		tToMirror.CurrencyID = id
+	}
+	if t.ForeignCurrencyID != nil {
+		if id, ok := config.CurrencyMapping[*t.ForeignCurrencyID]; ok {
+			tToMirror.ForeignCurrencyID = &id
+		} else {
+			tToMirror.ForeignCurrencyCode = t.ForeignCurrencyCode
+		}
+	}
+	if id, ok := config.AccountMapping[t.SourceID]; ok {
+		tToMirror.SourceID = id
+	} else {
+		tToMirror.SourceName = t.SourceName
+	}
+	if id, ok := config.AccountMapping[t.DestinationID]; ok {
+		tToMirror.DestinationID = id
+	} else {
+		tToMirror.DestinationName = t.DestinationName
+	}
+	if t.CategoryID != nil {
+		if id, ok := config.CategoryMapping[*t.CategoryID]; ok {
+			tToMirror.CategoryID = &id
+		} else {
+			tToMirror.CategoryName = t.CategoryName
+		}
+	}
+	if t.BudgetID != nil {
+		if id, ok := config.BudgetMapping[*t.BudgetID]; ok {
+			tToMirror.BudgetID = &id
+		}
+	}
+
+	return tToMirror
+}
+
+// linkCreatedTransaction will link the transaction journal to the created transaction using the given link type.
+func (a *Application) linkCreatedTransaction(
+	ctx context.Context,
+	linkTypeID string,
+	journalID string,
+	created *models.UpsertTransactionResponse,
+) error {
+	if len(created.Data.Attributes.Transactions) != 1 {
+		a.Logger.Debug("Created transaction has more than one transaction, skipping linking", "created", created)
+		return nil
+	}
+
+	outwardID := created.Data.Attributes.Transactions[0].TransactionJournalID
+	a.Logger.Debug("Linking transactions", "initial id", journalID, "created id", outwardID, "link type", linkTypeID)
+	return a.FireflyClient.LinkTransactionsContext(ctx, linkTypeID, journalID, outwardID)
+}
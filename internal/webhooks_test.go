@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorTransaction(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	date := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	config := firefly.MirrorConfig{
+		AccountMapping:  map[string]string{"1": "101", "2": "102"},
+		CategoryMapping: map[string]string{"3": "103"},
+		BudgetMapping:   map[string]string{"4": "104"},
+		CurrencyMapping: map[string]string{"1": "201", "2": "202"},
+	}
+
+	tests := []struct {
+		name        string
+		transaction models.Transaction
+		expected    models.Transaction
+	}{
+		{
+			name: "mapped ids",
+			transaction: models.Transaction{
+				Type:              string(firefly.WITHDRAWAL),
+				Amount:            "10.00",
+				ForeignAmount:     ptr("11.00"),
+				CurrencyID:        "1",
+				CurrencyCode:      "EUR",
+				ForeignCurrencyID: ptr("2"),
+				SourceID:          "1",
+				SourceName:        "Checking",
+				DestinationID:     "2",
+				DestinationName:   "Savings",
+				CategoryID:        ptr("3"),
+				CategoryName:      ptr("Groceries"),
+				BudgetID:          ptr("4"),
+				Description:       "Shopping",
+				Date:              date,
+				Notes:             ptr("Weekly"),
+				Tags:              []string{"food"},
+			},
+			expected: models.Transaction{
+				Type:              string(firefly.WITHDRAWAL),
+				Amount:            "10.00",
+				ForeignAmount:     ptr("11.00"),
+				CurrencyID:        "201",
+				CurrencyCode:      "EUR",
+				ForeignCurrencyID: ptr("202"),
+				SourceID:          "101",
+				DestinationID:     "102",
+				CategoryID:        ptr("103"),
+				BudgetID:          ptr("104"),
+				Description:       "Shopping",
+				Date:              date,
+				Notes:             ptr("Weekly"),
+				Tags:              []string{"food", "Webhook: mirror"},
+			},
+		},
+		{
+			name: "unmapped ids referenced by name",
+			transaction: models.Transaction{
+				Type:                string(firefly.WITHDRAWAL),
+				Amount:              "10.00",
+				CurrencyID:          "9",
+				CurrencyCode:        "USD",
+				ForeignCurrencyID:   ptr("8"),
+				ForeignCurrencyCode: ptr("GBP"),
+				SourceID:            "7",
+				SourceName:          "Wallet",
+				DestinationID:       "6",
+				DestinationName:     "Bakery",
+				CategoryID:          ptr("5"),
+				CategoryName:        ptr("Food"),
+				BudgetID:            ptr("9"),
+				Description:         "Bread",
+				Date:                date,
+				Tags:                []string{"Webhook: cashback", "daily"},
+			},
+			expected: models.Transaction{
+				Type:                string(firefly.WITHDRAWAL),
+				Amount:              "10.00",
+				CurrencyCode:        "USD",
+				ForeignCurrencyCode: ptr("GBP"),
+				SourceName:          "Wallet",
+				DestinationName:     "Bakery",
+				CategoryName:        ptr("Food"),
+				Description:         "Bread",
+				Date:                date,
+				Tags:                []string{"daily", "Webhook: mirror"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mirrorTransaction(&tt.transaction, config))
+		})
+	}
+}
+
+func TestMirrorClient(t *testing.T) {
+	var keys []string
+	server := newFireflyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	})
+	a := newTestApplication(t, server, `{}`)
+	config := func(apiKey string) firefly.MirrorConfig {
+		return firefly.MirrorConfig{BaseUrl: server.URL, ApiKey: firefly.Secret(apiKey)}
+	}
+
+	// Mirror configurations reaching the same instance with different keys, e.g. after a rotation
+	for _, apiKey := range []string{"first", "second", "first"} {
+		_, err := a.mirrorClient(config(apiKey)).GetBudget("1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Bearer first", "Bearer second", "Bearer first"}, keys)
+	assert.Same(t, a.mirrorClient(config("first")), a.mirrorClient(config("first")))
+	assert.Len(t, a.mirrorClients, 2)
+}
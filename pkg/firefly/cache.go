@@ -0,0 +1,42 @@
+package firefly
+
+import (
+	"sync"
+	"time"
+)
+
+// cache keeps the values loaded from Firefly for a while, to avoid requesting data that rarely changes.
+type cache[V any] struct {
+	entries map[string]cacheEntry[V]
+	ttl     time.Duration
+	mu      sync.Mutex
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newCache[V any](ttl time.Duration) *cache[V] {
+	return &cache[V]{entries: make(map[string]cacheEntry[V]), ttl: ttl}
+}
+
+// get returns the cached value for the key, loading it when missing or expired. Errors aren't cached.
+func (c *cache[V]) get(key string, load func() (V, error)) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry[V]{value: value, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return value, nil
+}
@@ -2,10 +2,18 @@ package firefly
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,7 +24,12 @@ import (
 // Firefly client used to interact with the Firefly III API.
 type Firefly struct {
 	httpClient *http.Client
-	baseUrl    string
+	// limiter caps the requests sent, shared by every caller of the client
+	limiter *limiter
+	// Currencies by id and by account id, which rarely change
+	currencies        *cache[CurrencyInfo]
+	accountCurrencies *cache[CurrencyInfo]
+	baseUrl           string
 	// Optional configuration options
 	fireflyOpts
 }
@@ -32,21 +45,40 @@ func NewFirefly(baseUrl string, opts ...FireflyOption) *Firefly {
 	if options.timeout == 0 {
 		options.timeout = defaultTimeout
 	}
+	if options.cacheTTL == 0 {
+		options.cacheTTL = defaultCacheTTL
+	}
+	if options.retry == nil {
+		options.retry = &DefaultRetryPolicy
+	}
+	if options.logger == nil {
+		options.logger = slog.Default()
+	}
 
 	return &Firefly{
 		baseUrl: baseUrl,
 		httpClient: &http.Client{
 			Timeout: options.timeout,
 		},
-		fireflyOpts: options,
+		currencies:        newCache[CurrencyInfo](options.cacheTTL),
+		accountCurrencies: newCache[CurrencyInfo](options.cacheTTL),
+		limiter:           newLimiter(options.limits, options.logger),
+		fireflyOpts:       options,
 	}
 }
 
-const defaultTimeout = 10 * time.Second
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultCacheTTL = time.Hour
+)
 
 type fireflyOpts struct {
-	apiKey  *string
-	timeout time.Duration
+	apiKey   *string
+	timeout  time.Duration
+	cacheTTL time.Duration
+	retry    *RetryPolicy
+	limits   limits
+	logger   *slog.Logger
 }
 
 // FireflyOption is a function that updates the fireflyOpts struct.
@@ -72,6 +104,14 @@ func WithTimeout(timeout time.Duration) FireflyOption {
 	}
 }
 
+// WithCacheTTL is a configuration function that updates how long currency metadata is cached.
+func WithCacheTTL(ttl time.Duration) FireflyOption {
+	return func(c *fireflyOpts) error {
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
 // addHeaders adds the required headers to the request.
 func (f *Firefly) addHeaders(req *http.Request) {
 	req.Header.Set("Accept", "application/json")
@@ -79,17 +119,12 @@ func (f *Firefly) addHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 }
 
-// handleHttpErrorResponse will read the response body and return a FireflyErrReply.
-func (f *Firefly) handleHttpErrorResponse(r *http.Response) error {
-	data, err := io.ReadAll(r.Body)
-	if err != nil {
-		return err
-	}
-
+// handleHttpErrorResponse will return the FireflyErrReply of the response with the given body. Bodies that
+// aren't a Firefly error, e.g. the page of a proxy failing to reach it, only report the status.
+func (f *Firefly) handleHttpErrorResponse(r *http.Response, data []byte) error {
 	var res models.FireflyErrReply
-	err = json.Unmarshal(data, &res)
-	if err != nil {
-		return err
+	if json.Unmarshal(data, &res) != nil {
+		res = models.FireflyErrReply{}
 	}
 
 	res.Code = r.StatusCode
@@ -98,39 +133,177 @@ func (f *Firefly) handleHttpErrorResponse(r *http.Response) error {
 	return res
 }
 
-// CreateTransaction will create a new transaction in Firefly III.
-func (f *Firefly) CreateTransaction(t *models.StoreTransactionRequest) (*models.UpsertTransactionResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/transactions", f.baseUrl)
-	data, err := json.Marshal(t)
+// doRequest will send a request to the Firefly III API with the given body encoded as JSON,
+// decoding the response body into res when it's not nil. The request is cancelled with the context, and retried
+// following the RetryPolicy when its method is idempotent.
+func (f *Firefly) doRequest(ctx context.Context, method string, path string, body any, res any) error {
+	_, err := f.doRetryableRequest(ctx, method, path, body, res, isIdempotent(method))
+	return err
+}
+
+// doRetryableRequest is like doRequest, retrying the request when retryable is true whatever its method.
+// It returns the number of attempts made as well.
+func (f *Firefly) doRetryableRequest(
+	ctx context.Context,
+	method string,
+	path string,
+	body any,
+	res any,
+	retryable bool,
+) (int, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		r, resBody, err := f.send(ctx, method, path, data)
+		if err == nil && r.StatusCode >= http.StatusOK && r.StatusCode < http.StatusMultipleChoices {
+			if res == nil || len(resBody) == 0 {
+				return attempt, nil
+			}
+			return attempt, json.Unmarshal(resBody, res)
+		}
+
+		retry := retryable && attempt < f.retry.MaxAttempts && shouldRetry(ctx, r, err)
+		var delay time.Duration
+		status := 0
+		if retry {
+			delay, retry = f.retry.delay(attempt, r)
+		}
+		if err == nil {
+			status = r.StatusCode
+			err = f.handleHttpErrorResponse(r, resBody)
+		}
+		if !retry {
+			return attempt, err
+		}
+
+		f.logger.Warn(
+			"Retrying Firefly III request",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Int("attempt", attempt),
+			slog.Int("status", status),
+			slog.Any("error", err),
+			slog.Duration("delay", delay),
+		)
+		if sleep(ctx, delay) != nil {
+			return attempt, err
+		}
+	}
+}
+
+// send sends a single request to the Firefly III API with the given JSON body, once the limits on the requests
+// sent allow it, returning the response with its body read.
+func (f *Firefly) send(ctx context.Context, method string, path string, data []byte) (*http.Response, []byte, error) {
+	release, err := f.limiter.acquire(ctx, method, path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	defer release()
+
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", f.baseUrl, path), reqBody)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	f.addHeaders(req)
 	r, err := f.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(r.Body)
 
-	if r.StatusCode != http.StatusOK {
-		return nil, f.handleHttpErrorResponse(r)
+	resBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	res, err := io.ReadAll(r.Body)
+	return r, resBody, nil
+}
+
+// CreateTransaction will create a new transaction in Firefly III.
+func (f *Firefly) CreateTransaction(t *models.StoreTransactionRequest) (*models.UpsertTransactionResponse, error) {
+	return f.CreateTransactionContext(context.Background(), t)
+}
+
+// CreateTransactionContext is like CreateTransaction, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CreateTransactionContext(
+	ctx context.Context,
+	t *models.StoreTransactionRequest,
+) (*models.UpsertTransactionResponse, error) {
+	var upsertTransaction models.UpsertTransactionResponse
+	// Transactions rejecting duplicates can't be created twice, so they're retried like idempotent requests
+	attempts, err := f.doRetryableRequest(
+		ctx,
+		http.MethodPost,
+		"/api/v1/transactions",
+		t,
+		&upsertTransaction,
+		t.ErrorIfDuplicateHash,
+	)
+	if id, ok := duplicateTransaction(err); ok && attempts > 1 {
+		// A previous attempt created the transaction but its response was lost, the retry being rejected as a duplicate
+		err = f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/transactions/%s", id), nil, &upsertTransaction)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return &upsertTransaction, nil
+}
+
+// duplicateTransactionMessage matches the message of the errors returned by Firefly when a transaction rejecting
+// duplicates has the same hash as an existing one, with the id of its group.
+var duplicateTransactionMessage = regexp.MustCompile(`Duplicate of transaction #(\d+)`)
+
+// duplicateTransaction returns the id of the existing transaction group when err rejects a duplicate transaction.
+func duplicateTransaction(err error) (string, bool) {
+	var reply models.FireflyErrReply
+	if !errors.As(err, &reply) || reply.Code != http.StatusUnprocessableEntity {
+		return "", false
+	}
+	messages := []string{reply.Message}
+	for _, field := range slices.Sorted(maps.Keys(reply.Errors)) {
+		messages = append(messages, reply.Errors[field]...)
+	}
+	for _, message := range messages {
+		if match := duplicateTransactionMessage.FindStringSubmatch(message); match != nil {
+			return match[1], true
+		}
+	}
+
+	return "", false
+}
+
+// UpdateTransaction will update an existing transaction in Firefly III.
+func (f *Firefly) UpdateTransaction(
+	id int,
+	t *models.UpdateTransactionRequest,
+) (*models.UpsertTransactionResponse, error) {
+	return f.UpdateTransactionContext(context.Background(), id, t)
+}
+
+// UpdateTransactionContext is like UpdateTransaction, cancelling the requests to Firefly III with the context.
+func (f *Firefly) UpdateTransactionContext(
+	ctx context.Context,
+	id int,
+	t *models.UpdateTransactionRequest,
+) (*models.UpsertTransactionResponse, error) {
 	var upsertTransaction models.UpsertTransactionResponse
-	err = json.Unmarshal(res, &upsertTransaction)
+	err := f.doRequest(ctx, http.MethodPut, fmt.Sprintf("/api/v1/transactions/%d", id), t, &upsertTransaction)
 	if err != nil {
 		return nil, err
 	}
@@ -138,77 +311,643 @@ func (f *Firefly) CreateTransaction(t *models.StoreTransactionRequest) (*models.
 	return &upsertTransaction, nil
 }
 
-// UpdateTransaction will create a new transaction in Firefly III.
-func (f *Firefly) UpdateTransaction(id int, t *models.UpdateTransactionRequest) (*models.UpsertTransactionResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/transactions/%d", f.baseUrl, id)
-	data, err := json.Marshal(t)
+// LinkTransactions will create a new link between two transactions in Firefly III.
+func (f *Firefly) LinkTransactions(linkTypeID string, inwardID string, outwardID string) error {
+	return f.LinkTransactionsContext(context.Background(), linkTypeID, inwardID, outwardID)
+}
+
+// LinkTransactionsContext is like LinkTransactions, cancelling the requests to Firefly III with the context.
+func (f *Firefly) LinkTransactionsContext(
+	ctx context.Context,
+	linkTypeID string,
+	inwardID string,
+	outwardID string,
+) error {
+	return f.doRequest(ctx, http.MethodPost, "/api/v1/transaction-links", models.StoreLinkRequest{
+		LinkTypeID: linkTypeID,
+		InwardID:   inwardID,
+		OutwardID:  outwardID,
+		Notes:      nil,
+	}, nil)
+}
+
+// GetPiggyBank will retrieve a piggy bank from Firefly III.
+func (f *Firefly) GetPiggyBank(id string) (*models.PiggyBankResponse, error) {
+	return f.GetPiggyBankContext(context.Background(), id)
+}
+
+// GetPiggyBankContext is like GetPiggyBank, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetPiggyBankContext(ctx context.Context, id string) (*models.PiggyBankResponse, error) {
+	var piggyBank models.PiggyBankResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/piggy-banks/%s", id), nil, &piggyBank)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(data))
+	return &piggyBank, nil
+}
+
+// SetPiggyBankAmount will set the current amount of a piggy bank in Firefly III, which records the difference
+// with the previous amount as a new piggy bank event. The amount is absolute: callers computing it from the
+// current one must not update the same piggy bank concurrently, or one of the changes is lost.
+func (f *Firefly) SetPiggyBankAmount(id string, currentAmount string) (*models.PiggyBankResponse, error) {
+	return f.SetPiggyBankAmountContext(context.Background(), id, currentAmount)
+}
+
+// SetPiggyBankAmountContext is like SetPiggyBankAmount, cancelling the requests to Firefly III with the context.
+func (f *Firefly) SetPiggyBankAmountContext(
+	ctx context.Context,
+	id string,
+	currentAmount string,
+) (*models.PiggyBankResponse, error) {
+	var piggyBank models.PiggyBankResponse
+	err := f.doRequest(ctx,
+		http.MethodPut,
+		fmt.Sprintf("/api/v1/piggy-banks/%s", id),
+		models.UpdatePiggyBankRequest{CurrentAmount: currentAmount},
+		&piggyBank,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	f.addHeaders(req)
-	r, err := f.httpClient.Do(req)
+	return &piggyBank, nil
+}
+
+// GetBudget will retrieve a budget from Firefly III.
+func (f *Firefly) GetBudget(id string) (*models.BudgetResponse, error) {
+	return f.GetBudgetContext(context.Background(), id)
+}
+
+// GetBudgetContext is like GetBudget, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetBudgetContext(ctx context.Context, id string) (*models.BudgetResponse, error) {
+	var budget models.BudgetResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/budgets/%s", id), nil, &budget)
 	if err != nil {
 		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(r.Body)
 
-	if r.StatusCode != http.StatusOK {
-		return nil, f.handleHttpErrorResponse(r)
-	}
+	return &budget, nil
+}
 
-	res, err := io.ReadAll(r.Body)
+// GetCurrency will retrieve a currency from Firefly III.
+func (f *Firefly) GetCurrency(id string) (*models.CurrencyResponse, error) {
+	return f.GetCurrencyContext(context.Background(), id)
+}
+
+// GetCurrencyContext is like GetCurrency, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetCurrencyContext(ctx context.Context, id string) (*models.CurrencyResponse, error) {
+	var currency models.CurrencyResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/currencies/%s", id), nil, &currency)
 	if err != nil {
 		return nil, err
 	}
 
-	var upsertTransaction models.UpsertTransactionResponse
-	err = json.Unmarshal(res, &upsertTransaction)
+	return &currency, nil
+}
+
+// GetAccount will retrieve an account from Firefly III.
+func (f *Firefly) GetAccount(id string) (*models.AccountResponse, error) {
+	return f.GetAccountContext(context.Background(), id)
+}
+
+// GetAccountContext is like GetAccount, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetAccountContext(ctx context.Context, id string) (*models.AccountResponse, error) {
+	var account models.AccountResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/accounts/%s", id), nil, &account)
 	if err != nil {
 		return nil, err
 	}
 
-	return &upsertTransaction, nil
+	return &account, nil
 }
 
-// LinkTransactions will create a new link between two transactions in Firefly III.
-func (f *Firefly) LinkTransactions(linkTypeID string, inwardID string, outwardID string) error {
-	url := fmt.Sprintf("%s/api/v1/transaction-links", f.baseUrl)
-	data, err := json.Marshal(models.StoreLinkRequest{
-		LinkTypeID: linkTypeID,
-		InwardID:   inwardID,
-		OutwardID:  outwardID,
-		Notes:      nil,
+// AccountType is an enum listing the types of accounts that can be listed.
+type AccountType string
+
+const (
+	ASSET_ACCOUNT     AccountType = "asset"
+	EXPENSE_ACCOUNT   AccountType = "expense"
+	REVENUE_ACCOUNT   AccountType = "revenue"
+	LIABILITY_ACCOUNT AccountType = "liabilities"
+)
+
+// ListAccounts will retrieve every account of the given type from Firefly III, or every account when the type
+// is empty.
+func (f *Firefly) ListAccounts(accountType AccountType) ([]models.AccountData, error) {
+	return f.ListAccountsContext(context.Background(), accountType)
+}
+
+// ListAccountsContext is like ListAccounts, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListAccountsContext(ctx context.Context, accountType AccountType) ([]models.AccountData, error) {
+	query := url.Values{}
+	if accountType != "" {
+		query.Set("type", string(accountType))
+	}
+
+	return listAll[models.AccountData](ctx, f, "/api/v1/accounts", query)
+}
+
+// SearchAccounts will retrieve every account whose name, IBAN or account number matches the query from
+// Firefly III, restricted to the given type unless empty.
+func (f *Firefly) SearchAccounts(query string, accountType AccountType) ([]models.AccountData, error) {
+	return f.SearchAccountsContext(context.Background(), query, accountType)
+}
+
+// SearchAccountsContext is like SearchAccounts, cancelling the requests to Firefly III with the context.
+func (f *Firefly) SearchAccountsContext(
+	ctx context.Context,
+	query string,
+	accountType AccountType,
+) ([]models.AccountData, error) {
+	values := url.Values{"query": {query}, "field": {"all"}}
+	if accountType != "" {
+		values.Set("type", string(accountType))
+	}
+
+	return listAll[models.AccountData](ctx, f, "/api/v1/search/accounts", values)
+}
+
+// CurrencyInfo is the currency metadata needed to write amounts.
+type CurrencyInfo struct {
+	ID            string
+	Code          string
+	DecimalPlaces int
+}
+
+// Currency returns the metadata of the currency with the given id, caching it.
+func (f *Firefly) Currency(id string) (CurrencyInfo, error) {
+	return f.CurrencyContext(context.Background(), id)
+}
+
+// CurrencyContext is like Currency, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CurrencyContext(ctx context.Context, id string) (CurrencyInfo, error) {
+	return f.currencies.get(id, func() (CurrencyInfo, error) {
+		currency, err := f.GetCurrencyContext(ctx, id)
+		if err != nil {
+			return CurrencyInfo{}, err
+		}
+
+		return CurrencyInfo{
+			ID:            currency.Data.ID,
+			Code:          currency.Data.Attributes.Code,
+			DecimalPlaces: currency.Data.Attributes.DecimalPlaces,
+		}, nil
 	})
+}
+
+// AccountCurrency returns the metadata of the currency of the account with the given id, caching it.
+func (f *Firefly) AccountCurrency(accountID string) (CurrencyInfo, error) {
+	return f.AccountCurrencyContext(context.Background(), accountID)
+}
+
+// AccountCurrencyContext is like AccountCurrency, cancelling the requests to Firefly III with the context.
+func (f *Firefly) AccountCurrencyContext(ctx context.Context, accountID string) (CurrencyInfo, error) {
+	return f.accountCurrencies.get(accountID, func() (CurrencyInfo, error) {
+		account, err := f.GetAccountContext(ctx, accountID)
+		if err != nil {
+			return CurrencyInfo{}, err
+		}
+
+		return CurrencyInfo{
+			ID:            account.Data.Attributes.CurrencyID,
+			Code:          account.Data.Attributes.CurrencyCode,
+			DecimalPlaces: account.Data.Attributes.CurrencyDecimalPlaces,
+		}, nil
+	})
+}
+
+// ListBudgetLimits will retrieve the limits of a budget overlapping the given period from Firefly III.
+func (f *Firefly) ListBudgetLimits(budgetID string, start time.Time, end time.Time) ([]models.BudgetLimitData, error) {
+	return f.ListBudgetLimitsContext(context.Background(), budgetID, start, end)
+}
+
+// ListBudgetLimitsContext is like ListBudgetLimits, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListBudgetLimitsContext(
+	ctx context.Context,
+	budgetID string,
+	start time.Time,
+	end time.Time,
+) ([]models.BudgetLimitData, error) {
+	query := url.Values{}
+	query.Set("start", start.Format(time.DateOnly))
+	query.Set("end", end.Format(time.DateOnly))
+
+	var limits models.BudgetLimitListResponse
+	err := f.doRequest(ctx,
+		http.MethodGet,
+		fmt.Sprintf("/api/v1/budgets/%s/limits?%s", budgetID, query.Encode()),
+		nil,
+		&limits,
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	return limits.Data, nil
+}
+
+// ListTags will retrieve every tag from Firefly III.
+func (f *Firefly) ListTags() ([]models.TagData, error) {
+	return f.ListTagsContext(context.Background())
+}
+
+// ListTagsContext is like ListTags, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListTagsContext(ctx context.Context) ([]models.TagData, error) {
+	return listAll[models.TagData](ctx, f, "/api/v1/tags", nil)
+}
+
+// GetTag will retrieve a tag, given by id or by name, from Firefly III.
+func (f *Firefly) GetTag(tag string) (*models.TagResponse, error) {
+	return f.GetTagContext(context.Background(), tag)
+}
+
+// GetTagContext is like GetTag, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetTagContext(ctx context.Context, tag string) (*models.TagResponse, error) {
+	var res models.TagResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/tags/%s", url.PathEscape(tag)), nil, &res)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	f.addHeaders(req)
-	r, err := f.httpClient.Do(req)
+	return &res, nil
+}
+
+// CreateTag will create a new tag in Firefly III.
+func (f *Firefly) CreateTag(t *models.StoreTagRequest) (*models.TagResponse, error) {
+	return f.CreateTagContext(context.Background(), t)
+}
+
+// CreateTagContext is like CreateTag, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CreateTagContext(ctx context.Context, t *models.StoreTagRequest) (*models.TagResponse, error) {
+	var res models.TagResponse
+	err := f.doRequest(ctx, http.MethodPost, "/api/v1/tags", t, &res)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(r.Body)
 
-	if r.StatusCode != http.StatusOK {
-		return f.handleHttpErrorResponse(r)
+	return &res, nil
+}
+
+// EnsureTag returns the id of the tag with the given name, creating it in Firefly III when missing.
+func (f *Firefly) EnsureTag(tag string) (string, error) {
+	return f.EnsureTagContext(context.Background(), tag)
+}
+
+// EnsureTagContext is like EnsureTag, cancelling the requests to Firefly III with the context.
+func (f *Firefly) EnsureTagContext(ctx context.Context, tag string) (string, error) {
+	res, err := f.GetTagContext(ctx, tag)
+	if isNotFound(err) {
+		res, err = f.CreateTagContext(ctx, &models.StoreTagRequest{Tag: tag})
+	}
+	if err != nil {
+		return "", err
 	}
 
-	_, err = io.ReadAll(r.Body)
-	return err
+	return res.Data.ID, nil
+}
+
+// ListCategories will retrieve every category from Firefly III.
+func (f *Firefly) ListCategories() ([]models.CategoryData, error) {
+	return f.ListCategoriesContext(context.Background())
+}
+
+// ListCategoriesContext is like ListCategories, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListCategoriesContext(ctx context.Context) ([]models.CategoryData, error) {
+	return listAll[models.CategoryData](ctx, f, "/api/v1/categories", nil)
+}
+
+// GetCategory will retrieve a category from Firefly III.
+func (f *Firefly) GetCategory(id string) (*models.CategoryResponse, error) {
+	return f.GetCategoryContext(context.Background(), id)
+}
+
+// GetCategoryContext is like GetCategory, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetCategoryContext(ctx context.Context, id string) (*models.CategoryResponse, error) {
+	var category models.CategoryResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/categories/%s", id), nil, &category)
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// CreateCategory will create a new category in Firefly III.
+func (f *Firefly) CreateCategory(c *models.StoreCategoryRequest) (*models.CategoryResponse, error) {
+	return f.CreateCategoryContext(context.Background(), c)
+}
+
+// CreateCategoryContext is like CreateCategory, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CreateCategoryContext(
+	ctx context.Context,
+	c *models.StoreCategoryRequest,
+) (*models.CategoryResponse, error) {
+	var category models.CategoryResponse
+	err := f.doRequest(ctx, http.MethodPost, "/api/v1/categories", c, &category)
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// EnsureCategory returns the id of the category with the given name, creating it in Firefly III when missing.
+func (f *Firefly) EnsureCategory(name string) (string, error) {
+	return f.EnsureCategoryContext(context.Background(), name)
+}
+
+// EnsureCategoryContext is like EnsureCategory, cancelling the requests to Firefly III with the context.
+func (f *Firefly) EnsureCategoryContext(ctx context.Context, name string) (string, error) {
+	lookup, err := f.CategoryLookupContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	id, err := lookup.ID(name)
+	if !errors.Is(err, ErrFireflyNameNotFound) {
+		return id, err
+	}
+	category, err := f.CreateCategoryContext(ctx, &models.StoreCategoryRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+
+	return category.Data.ID, nil
+}
+
+// ListBudgets will retrieve every budget from Firefly III.
+func (f *Firefly) ListBudgets() ([]models.BudgetData, error) {
+	return f.ListBudgetsContext(context.Background())
+}
+
+// ListBudgetsContext is like ListBudgets, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListBudgetsContext(ctx context.Context) ([]models.BudgetData, error) {
+	return listAll[models.BudgetData](ctx, f, "/api/v1/budgets", nil)
+}
+
+// CreateBudget will create a new budget in Firefly III.
+func (f *Firefly) CreateBudget(b *models.StoreBudgetRequest) (*models.BudgetResponse, error) {
+	return f.CreateBudgetContext(context.Background(), b)
+}
+
+// CreateBudgetContext is like CreateBudget, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CreateBudgetContext(
+	ctx context.Context,
+	b *models.StoreBudgetRequest,
+) (*models.BudgetResponse, error) {
+	var budget models.BudgetResponse
+	err := f.doRequest(ctx, http.MethodPost, "/api/v1/budgets", b, &budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return &budget, nil
+}
+
+// CreateBudgetLimit will create a new limit of a budget in Firefly III.
+func (f *Firefly) CreateBudgetLimit(
+	budgetID string,
+	l *models.StoreBudgetLimitRequest,
+) (*models.BudgetLimitResponse, error) {
+	return f.CreateBudgetLimitContext(context.Background(), budgetID, l)
+}
+
+// CreateBudgetLimitContext is like CreateBudgetLimit, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CreateBudgetLimitContext(
+	ctx context.Context,
+	budgetID string,
+	l *models.StoreBudgetLimitRequest,
+) (*models.BudgetLimitResponse, error) {
+	var limit models.BudgetLimitResponse
+	err := f.doRequest(ctx, http.MethodPost, fmt.Sprintf("/api/v1/budgets/%s/limits", budgetID), l, &limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &limit, nil
+}
+
+// UpdateBudgetLimit will update an existing limit of a budget in Firefly III.
+func (f *Firefly) UpdateBudgetLimit(
+	budgetID string,
+	limitID string,
+	l *models.StoreBudgetLimitRequest,
+) (*models.BudgetLimitResponse, error) {
+	return f.UpdateBudgetLimitContext(context.Background(), budgetID, limitID, l)
+}
+
+// UpdateBudgetLimitContext is like UpdateBudgetLimit, cancelling the requests to Firefly III with the context.
+func (f *Firefly) UpdateBudgetLimitContext(
+	ctx context.Context,
+	budgetID string,
+	limitID string,
+	l *models.StoreBudgetLimitRequest,
+) (*models.BudgetLimitResponse, error) {
+	var limit models.BudgetLimitResponse
+	err := f.doRequest(ctx, http.MethodPut, fmt.Sprintf("/api/v1/budgets/%s/limits/%s", budgetID, limitID), l, &limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &limit, nil
+}
+
+// isNotFound checks if the request failed because Firefly III has no such resource.
+func isNotFound(err error) bool {
+	var reply models.FireflyErrReply
+	return errors.As(err, &reply) && reply.Code == http.StatusNotFound
+}
+
+// DeleteTransaction will delete a transaction group from Firefly III.
+func (f *Firefly) DeleteTransaction(id string) error {
+	return f.DeleteTransactionContext(context.Background(), id)
+}
+
+// DeleteTransactionContext is like DeleteTransaction, cancelling the requests to Firefly III with the context.
+func (f *Firefly) DeleteTransactionContext(ctx context.Context, id string) error {
+	return f.doRequest(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/transactions/%s", id), nil, nil)
+}
+
+// GetTransaction will retrieve a transaction group from Firefly III.
+func (f *Firefly) GetTransaction(id string) (*models.TransactionGroupResponse, error) {
+	return f.GetTransactionContext(context.Background(), id)
+}
+
+// GetTransactionContext is like GetTransaction, cancelling the requests to Firefly III with the context.
+func (f *Firefly) GetTransactionContext(ctx context.Context, id string) (*models.TransactionGroupResponse, error) {
+	var transaction models.TransactionGroupResponse
+	err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("/api/v1/transactions/%s", id), nil, &transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// TransactionFilter restricts the transactions listed, its zero fields don't filter.
+type TransactionFilter struct {
+	// Start and End are the first and the last day of the transactions, included.
+	Start time.Time
+	End   time.Time
+	// Type is the type of the transactions, e.g. WITHDRAWAL.
+	Type TransactionType
+}
+
+// query returns the query parameters of the filter.
+func (tf TransactionFilter) query() url.Values {
+	query := url.Values{}
+	if !tf.Start.IsZero() {
+		query.Set("start", tf.Start.Format(time.DateOnly))
+	}
+	if !tf.End.IsZero() {
+		query.Set("end", tf.End.Format(time.DateOnly))
+	}
+	if tf.Type != "" {
+		query.Set("type", string(tf.Type))
+	}
+
+	return query
+}
+
+// ListTransactions will retrieve every transaction group matching the filter from Firefly III.
+func (f *Firefly) ListTransactions(filter TransactionFilter) ([]models.TransactionGroupData, error) {
+	return f.ListTransactionsContext(context.Background(), filter)
+}
+
+// ListTransactionsContext is like ListTransactions, cancelling the requests to Firefly III with the context.
+func (f *Firefly) ListTransactionsContext(
+	ctx context.Context,
+	filter TransactionFilter,
+) ([]models.TransactionGroupData, error) {
+	return listAll[models.TransactionGroupData](ctx, f, "/api/v1/transactions", filter.query())
+}
+
+// SearchTransactions will retrieve every transaction group matching the query from Firefly III, written in the
+// syntax of its search, e.g. "tag:cashback date_after:2024-01-01".
+func (f *Firefly) SearchTransactions(query string) ([]models.TransactionGroupData, error) {
+	return f.SearchTransactionsContext(context.Background(), query)
+}
+
+// SearchTransactionsContext is like SearchTransactions, cancelling the requests to Firefly III with the context.
+func (f *Firefly) SearchTransactionsContext(ctx context.Context, query string) ([]models.TransactionGroupData, error) {
+	return listAll[models.TransactionGroupData](ctx, f, "/api/v1/search/transactions", url.Values{"query": {query}})
+}
+
+// listAll requests every page of a list endpoint with the given query parameters.
+func listAll[T any](ctx context.Context, f *Firefly, path string, query url.Values) ([]T, error) {
+	query = maps.Clone(query)
+	if query == nil {
+		query = url.Values{}
+	}
+	var all []T
+	for page := 1; ; page++ {
+		var res models.ListResponse[T]
+		query.Set("page", strconv.Itoa(page))
+		err := f.doRequest(ctx, http.MethodGet, fmt.Sprintf("%s?%s", path, query.Encode()), nil, &res)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, res.Data...)
+		if page >= res.Meta.Pagination.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+// AccountLookup indexes the ids of every account by name.
+func (f *Firefly) AccountLookup() (*Lookup, error) {
+	return f.AccountLookupContext(context.Background())
+}
+
+// AccountLookupContext is like AccountLookup, cancelling the requests to Firefly III with the context.
+func (f *Firefly) AccountLookupContext(ctx context.Context) (*Lookup, error) {
+	accounts, err := f.ListAccountsContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	lookup := newLookup(AccountRef)
+	for _, a := range accounts {
+		lookup.add(a.Attributes.Name, a.ID)
+	}
+
+	return lookup, nil
+}
+
+// CategoryLookup indexes the ids of every category by name.
+func (f *Firefly) CategoryLookup() (*Lookup, error) {
+	return f.CategoryLookupContext(context.Background())
+}
+
+// CategoryLookupContext is like CategoryLookup, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CategoryLookupContext(ctx context.Context) (*Lookup, error) {
+	categories, err := f.ListCategoriesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lookup := newLookup(CategoryRef)
+	for _, c := range categories {
+		lookup.add(c.Attributes.Name, c.ID)
+	}
+
+	return lookup, nil
+}
+
+// BudgetLookup indexes the ids of every budget by name.
+func (f *Firefly) BudgetLookup() (*Lookup, error) {
+	return f.BudgetLookupContext(context.Background())
+}
+
+// BudgetLookupContext is like BudgetLookup, cancelling the requests to Firefly III with the context.
+func (f *Firefly) BudgetLookupContext(ctx context.Context) (*Lookup, error) {
+	budgets, err := f.ListBudgetsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lookup := newLookup(BudgetRef)
+	for _, b := range budgets {
+		lookup.add(b.Attributes.Name, b.ID)
+	}
+
+	return lookup, nil
+}
+
+// CurrencyLookup indexes the ids of every currency by code and by name.
+func (f *Firefly) CurrencyLookup() (*Lookup, error) {
+	return f.CurrencyLookupContext(context.Background())
+}
+
+// CurrencyLookupContext is like CurrencyLookup, cancelling the requests to Firefly III with the context.
+func (f *Firefly) CurrencyLookupContext(ctx context.Context) (*Lookup, error) {
+	currencies, err := listAll[models.CurrencyData](ctx, f, "/api/v1/currencies", nil)
+	if err != nil {
+		return nil, err
+	}
+	lookup := newLookup(CurrencyRef)
+	for _, c := range currencies {
+		lookup.add(c.Attributes.Code, c.ID)
+		if c.Attributes.Name != c.Attributes.Code {
+			lookup.add(c.Attributes.Name, c.ID)
+		}
+	}
+
+	return lookup, nil
+}
+
+// LinkTypeLookup indexes the ids of every link type by name.
+func (f *Firefly) LinkTypeLookup() (*Lookup, error) {
+	return f.LinkTypeLookupContext(context.Background())
+}
+
+// LinkTypeLookupContext is like LinkTypeLookup, cancelling the requests to Firefly III with the context.
+func (f *Firefly) LinkTypeLookupContext(ctx context.Context) (*Lookup, error) {
+	linkTypes, err := listAll[models.LinkTypeData](ctx, f, "/api/v1/link-types", nil)
+	if err != nil {
+		return nil, err
+	}
+	lookup := newLookup(LinkTypeRef)
+	for _, l := range linkTypes {
+		lookup.add(l.Attributes.Name, l.ID)
+	}
+
+	return lookup, nil
 }
@@ -0,0 +1,356 @@
+package firefly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyCache(t *testing.T) {
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/accounts/4":
+			fmt.Fprint(w, `{"data": {"type": "accounts", "id": "4", "attributes": {
+				"name": "Checking", "currency_id": "1", "currency_code": "EUR", "currency_decimal_places": 2}}}`)
+		case "/api/v1/currencies/3":
+			fmt.Fprint(w, `{"data": {"type": "currencies", "id": "3", "attributes": {"code": "JPY", "decimal_places": 0}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Resource not found", "exception": "NotFoundHttpException"}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewFirefly(server.URL, WithApiKey("key"), WithCacheTTL(time.Minute))
+	for range 2 {
+		currency, err := client.AccountCurrency("4")
+		require.NoError(t, err)
+		assert.Equal(t, CurrencyInfo{ID: "1", Code: "EUR", DecimalPlaces: 2}, currency)
+
+		currency, err = client.Currency("3")
+		require.NoError(t, err)
+		assert.Equal(t, CurrencyInfo{ID: "3", Code: "JPY", DecimalPlaces: 0}, currency)
+	}
+	_, err := client.AccountCurrency("5")
+	assert.Error(t, err)
+	_, err = client.AccountCurrency("5")
+	assert.Error(t, err)
+
+	assert.Equal(t, map[string]int{"/api/v1/accounts/4": 1, "/api/v1/currencies/3": 1, "/api/v1/accounts/5": 2}, requests)
+}
+
+func TestRequestContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewFirefly(server.URL, WithApiKey("key"), WithTimeout(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := client.LinkTransactionsContext(ctx, "1", "2", "3")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.GetBudgetContext(ctx, "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	client = NewFirefly(
+		server.URL,
+		WithApiKey("key"),
+		WithTimeout(50*time.Millisecond),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	)
+	_, err = client.GetBudgetContext(context.Background(), "1")
+	assert.Error(t, err, "the timeout of the client applies to each request")
+}
+
+func TestTransactions(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/transactions/2":
+			fmt.Fprint(w, `{"data": {"type": "transactions", "id": "2", "attributes": {
+				"created_at": "2024-10-25T12:09:31+02:00", "group_title": null, "transactions": [
+					{"transaction_journal_id": "2", "type": "withdrawal", "amount": "11.00", "description": "Lunch",
+					 "date": "2024-10-25T12:09:00+02:00", "budget_id": null, "tags": ["food"]}]}}}`)
+		case r.URL.Path == "/api/v1/transactions" || r.URL.Path == "/api/v1/search/transactions":
+			page := r.URL.Query().Get("page")
+			fmt.Fprintf(w, `{"data": [{"type": "transactions", "id": "%s", "attributes": {"transactions": []}}],
+				"meta": {"pagination": {"total": 2, "count": 1, "per_page": 1, "current_page": %s, "total_pages": 2}}}`, page, page)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Resource not found", "exception": "NotFoundHttpException"}`)
+		}
+	}))
+	defer server.Close()
+	client := NewFirefly(server.URL, WithApiKey("key"))
+
+	transaction, err := client.GetTransaction("2")
+	require.NoError(t, err)
+	assert.Equal(t, "2", transaction.Data.ID)
+	assert.Nil(t, transaction.Data.Attributes.GroupTitle)
+	require.Len(t, transaction.Data.Attributes.Transactions, 1)
+	assert.Equal(t, "Lunch", transaction.Data.Attributes.Transactions[0].Description)
+	assert.Equal(t, "11.00", transaction.Data.Attributes.Transactions[0].Amount)
+
+	_, err = client.GetTransaction("3")
+	assert.ErrorContains(t, err, "status 404")
+
+	transactions, err := client.ListTransactions(TransactionFilter{
+		Start: time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, time.October, 31, 0, 0, 0, 0, time.UTC),
+		Type:  WITHDRAWAL,
+	})
+	require.NoError(t, err)
+	assert.Len(t, transactions, 2)
+
+	transactions, err = client.SearchTransactions("tag:food amount_more:10")
+	require.NoError(t, err)
+	assert.Len(t, transactions, 2)
+
+	require.NoError(t, client.DeleteTransaction("2"))
+
+	assert.Equal(t, []string{
+		"GET /api/v1/transactions/2",
+		"GET /api/v1/transactions/3",
+		"GET /api/v1/transactions?end=2024-10-31&page=1&start=2024-10-01&type=withdrawal",
+		"GET /api/v1/transactions?end=2024-10-31&page=2&start=2024-10-01&type=withdrawal",
+		"GET /api/v1/search/transactions?page=1&query=tag%3Afood+amount_more%3A10",
+		"GET /api/v1/search/transactions?page=2&query=tag%3Afood+amount_more%3A10",
+		"DELETE /api/v1/transactions/2",
+	}, requests)
+}
+
+func TestAccounts(t *testing.T) {
+	data, err := os.ReadFile("../../example/account.json")
+	require.NoError(t, err)
+	var example struct {
+		Content []json.RawMessage `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal(data, &example))
+	loan := json.RawMessage(`{"name": "Mortgage", "type": "liabilities", "account_role": null, "currency_id": "1",
+		"current_balance": "-150000.00", "liability_type": "mortgage", "liability_direction": "credit",
+		"interest": "2.5", "interest_period": "monthly", "current_debt": "150000.00"}`)
+	accounts := append(example.Content, loan)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		var page []json.RawMessage
+		switch r.URL.Path {
+		case "/api/v1/accounts":
+			page = accounts
+		case "/api/v1/search/accounts":
+			page = accounts[2:]
+		}
+		var res models.ListResponse[json.RawMessage]
+		for i, account := range page {
+			res.Data = append(res.Data, json.RawMessage(fmt.Sprintf(`{"type": "accounts", "id": "%d", "attributes": %s}`, i+1, account)))
+		}
+		res.Meta.Pagination.TotalPages = 1
+		assert.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer server.Close()
+	client := NewFirefly(server.URL, WithApiKey("key"))
+
+	list, err := client.ListAccounts("")
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	asset := list[0].Attributes
+	assert.Equal(t, "Ticket Restaurant", asset.Name)
+	require.NotNil(t, asset.AccountRole)
+	assert.Equal(t, "defaultAsset", *asset.AccountRole)
+	assert.Equal(t, "1", asset.CurrencyID)
+	assert.Nil(t, asset.Iban)
+	assert.False(t, asset.IsLiability())
+	balance, err := asset.Balance()
+	require.NoError(t, err)
+	assert.Equal(t, -21.0, balance)
+
+	list, err = client.SearchAccounts("mortgage", LIABILITY_ACCOUNT)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	mortgage := list[0].Attributes
+	assert.True(t, mortgage.IsLiability())
+	assert.Equal(t, "credit", *mortgage.LiabilityDirection)
+	assert.Equal(t, "monthly", *mortgage.InterestPeriod)
+	debt, err := mortgage.Debt()
+	require.NoError(t, err)
+	assert.Equal(t, 150000.0, debt)
+
+	_, err = client.ListAccounts(ASSET_ACCOUNT)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/api/v1/accounts?page=1",
+		"/api/v1/search/accounts?field=all&page=1&query=mortgage&type=liabilities",
+		"/api/v1/accounts?page=1&type=asset",
+	}, requests)
+}
+
+func TestEnsureTagsAndCategories(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+		switch {
+		case r.URL.Path == "/api/v1/tags/groceries":
+			fmt.Fprint(w, `{"data": {"type": "tags", "id": "3", "attributes": {"tag": "groceries"}}}`)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v1/tags/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Resource not found"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/tags":
+			fmt.Fprint(w, `{"data": {"type": "tags", "id": "4", "attributes": {"tag": "trip 2026"}}}`)
+		case r.URL.Path == "/api/v1/categories" && r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"data": [{"type": "categories", "id": "1", "attributes": {"name": "Groceries"}}],
+				"meta": {"pagination": {"total_pages": 1}}}`)
+		case r.URL.Path == "/api/v1/categories":
+			fmt.Fprint(w, `{"data": {"type": "categories", "id": "2", "attributes": {"name": "Travel"}}}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client := NewFirefly(server.URL, WithApiKey("key"))
+
+	id, err := client.EnsureTag("groceries")
+	require.NoError(t, err)
+	assert.Equal(t, "3", id)
+	id, err = client.EnsureTag("trip 2026")
+	require.NoError(t, err)
+	assert.Equal(t, "4", id)
+	id, err = client.EnsureCategory("Groceries")
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+	id, err = client.EnsureCategory("Travel")
+	require.NoError(t, err)
+	assert.Equal(t, "2", id)
+
+	assert.Equal(t, []string{
+		"GET /api/v1/tags/groceries",
+		"GET /api/v1/tags/trip%202026",
+		`POST /api/v1/tags {"tag":"trip 2026"}`,
+		"GET /api/v1/categories?page=1",
+		"GET /api/v1/categories?page=1",
+		`POST /api/v1/categories {"name":"Travel"}`,
+	}, requests)
+}
+
+func TestBudgetLimitUsage(t *testing.T) {
+	spent := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		limit    models.BudgetLimit
+		expected models.BudgetUsage
+		err      string
+	}{
+		{
+			name:     "part of the amount spent",
+			limit:    models.BudgetLimit{Amount: "200.00", Spent: spent("-50.00")},
+			expected: models.BudgetUsage{Amount: 200, Spent: 50, Percentage: 25},
+		},
+		{
+			name:     "amount exceeded",
+			limit:    models.BudgetLimit{Amount: "100", Spent: spent("-150")},
+			expected: models.BudgetUsage{Amount: 100, Spent: 150, Percentage: 150},
+		},
+		{
+			name:     "spent not computed",
+			limit:    models.BudgetLimit{Amount: "100"},
+			expected: models.BudgetUsage{Amount: 100},
+		},
+		{
+			name:     "no amount",
+			limit:    models.BudgetLimit{Amount: "0", Spent: spent("-10")},
+			expected: models.BudgetUsage{Spent: 10},
+		},
+		{
+			name:  "invalid amount",
+			limit: models.BudgetLimit{Amount: "ten"},
+			err:   `invalid budget limit amount "ten"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := tt.limit.Usage()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, usage)
+		})
+	}
+}
+
+func TestBudgetLimits(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+		fmt.Fprint(w, `{"data": {"type": "budget_limits", "id": "7", "attributes": {"budget_id": "2", "amount": "300.00",
+			"start": "2026-10-01T00:00:00+00:00", "end": "2026-10-31T23:59:59+00:00"}}}`)
+	}))
+	defer server.Close()
+	client := NewFirefly(server.URL, WithApiKey("key"))
+
+	limit := &models.StoreBudgetLimitRequest{Start: "2026-10-01", End: "2026-10-31", Amount: "300.00"}
+	res, err := client.CreateBudgetLimit("2", limit)
+	require.NoError(t, err)
+	assert.Equal(t, "7", res.Data.ID)
+	assert.Equal(t, "300.00", res.Data.Attributes.Amount)
+	_, err = client.UpdateBudgetLimit("2", "7", limit)
+	require.NoError(t, err)
+
+	body := `{"start":"2026-10-01","end":"2026-10-31","amount":"300.00"}`
+	assert.Equal(t, []string{
+		"POST /api/v1/budgets/2/limits " + body,
+		"PUT /api/v1/budgets/2/limits/7 " + body,
+	}, requests)
+}
+
+func TestSetPiggyBankAmount(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+		fmt.Fprint(w, `{"data": {"type": "piggy_banks", "id": "2", "attributes": {"name": "Holidays",
+			"current_amount": "150.00", "target_amount": "1000.00", "currency_decimal_places": 2}}}`)
+	}))
+	defer server.Close()
+
+	res, err := NewFirefly(server.URL, WithApiKey("key")).SetPiggyBankAmount("2", "150.00")
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", res.Data.Attributes.Name)
+	assert.Equal(t, "150.00", res.Data.Attributes.CurrentAmount)
+	assert.Equal(t, []string{`PUT /api/v1/piggy-banks/2 {"current_amount":"150.00"}`}, requests)
+}
@@ -0,0 +1,104 @@
+package firefly
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CommandName is an enum listing all commands recognised in transaction notes.
+type CommandName string
+
+const (
+	SPLIT_COMMAND    CommandName = "split"
+	CASHBACK_COMMAND CommandName = "cashback"
+	TRANSFER_COMMAND CommandName = "transfer"
+)
+
+// commandRegexp matches commands like "!transfer savings 10" up to the end of the line or the next command.
+var commandRegexp = regexp.MustCompile(`(?m)(^|[ \t])!(split|cashback|transfer)\b((?:[ \t]+[^\s!]+)*)`)
+
+// Command is an instruction found in the notes of a transaction, e.g. "!split alice 50%".
+type Command struct {
+	Name CommandName
+	Args []string
+	// Raw is the command as written in the notes, without the leading "!".
+	Raw string
+}
+
+// ParseCommands returns the commands found in the given notes, in order.
+func ParseCommands(notes string) []Command {
+	var commands []Command
+	for _, match := range commandRegexp.FindAllStringSubmatch(notes, -1) {
+		commands = append(commands, newCommand(match))
+	}
+
+	return commands
+}
+
+// HasCommands checks if the given notes contain at least one command.
+func HasCommands(notes *string) bool {
+	return notes != nil && commandRegexp.MatchString(*notes)
+}
+
+// ReplaceCommands returns the notes with every command replaced by the text returned by replace.
+// The commands are passed to replace in the same order returned by ParseCommands.
+func ReplaceCommands(notes string, replace func(idx int, c Command) string) string {
+	var b strings.Builder
+	last := 0
+	for i, loc := range commandRegexp.FindAllStringSubmatchIndex(notes, -1) {
+		match := make([]string, len(loc)/2)
+		for j := range match {
+			match[j] = notes[loc[2*j]:loc[2*j+1]]
+		}
+		// Keep the whitespace preceding the command
+		b.WriteString(notes[last:loc[3]])
+		b.WriteString(replace(i, newCommand(match)))
+		last = loc[1]
+	}
+	b.WriteString(notes[last:])
+
+	return b.String()
+}
+
+// newCommand creates a Command from the submatches of commandRegexp.
+func newCommand(match []string) Command {
+	args := strings.Fields(match[3])
+	return Command{
+		Name: CommandName(match[2]),
+		Args: args,
+		Raw:  strings.Join(append([]string{match[2]}, args...), " "),
+	}
+}
+
+// CommandAmount is an amount argument of a command, either absolute or a percentage of the transaction amount.
+type CommandAmount struct {
+	Value      float64
+	Percentage bool
+}
+
+// ParseCommandAmount parses amounts like "10", "10,5" or "2%".
+func ParseCommandAmount(arg string) (CommandAmount, error) {
+	var amount CommandAmount
+	value, found := strings.CutSuffix(strings.TrimSpace(arg), "%")
+	amount.Percentage = found
+	parsed, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil {
+		return amount, ErrFireflyInvalidCommandAmount
+	}
+	if parsed <= 0 {
+		return amount, ErrFireflyInvalidCommandAmount
+	}
+	amount.Value = parsed
+
+	return amount, nil
+}
+
+// Of returns the amount resolved against the given transaction amount.
+func (a CommandAmount) Of(amount float64) float64 {
+	if a.Percentage {
+		return amount * a.Value / 100
+	}
+
+	return a.Value
+}
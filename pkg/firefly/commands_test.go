@@ -0,0 +1,91 @@
+package firefly
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    string
+		expected []Command
+	}{
+		{
+			name:     "no commands",
+			notes:    "Dinner with friends!",
+			expected: nil,
+		},
+		{
+			name:     "unknown command",
+			notes:    "!refund 10",
+			expected: nil,
+		},
+		{
+			name:  "single command",
+			notes: "!cashback 2%",
+			expected: []Command{
+				{Name: CASHBACK_COMMAND, Args: []string{"2%"}, Raw: "cashback 2%"},
+			},
+		},
+		{
+			name:  "commands on multiple lines and inline",
+			notes: "Dinner\n!split alice 50%\nthen !transfer  savings 10",
+			expected: []Command{
+				{Name: SPLIT_COMMAND, Args: []string{"alice", "50%"}, Raw: "split alice 50%"},
+				{Name: TRANSFER_COMMAND, Args: []string{"savings", "10"}, Raw: "transfer savings 10"},
+			},
+		},
+		{
+			name:  "commands on the same line",
+			notes: "!cashback 1 !transfer savings 10",
+			expected: []Command{
+				{Name: CASHBACK_COMMAND, Args: []string{"1"}, Raw: "cashback 1"},
+				{Name: TRANSFER_COMMAND, Args: []string{"savings", "10"}, Raw: "transfer savings 10"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseCommands(tt.notes))
+		})
+	}
+}
+
+func TestReplaceCommands(t *testing.T) {
+	notes := "Dinner\n!split alice 50%\nthen !transfer savings 10"
+
+	stripped := ReplaceCommands(notes, func(int, Command) string { return "" })
+	assert.Equal(t, "Dinner\n\nthen ", stripped)
+
+	annotated := ReplaceCommands(notes, func(i int, c Command) string { return "done " + c.Raw })
+	assert.Equal(t, "Dinner\ndone split alice 50%\nthen done transfer savings 10", annotated)
+	assert.Empty(t, ParseCommands(annotated))
+}
+
+func TestParseCommandAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		arg      string
+		expected CommandAmount
+		err      error
+	}{
+		{name: "absolute", arg: "10", expected: CommandAmount{Value: 10}},
+		{name: "decimal comma", arg: "10,5", expected: CommandAmount{Value: 10.5}},
+		{name: "percentage", arg: "2%", expected: CommandAmount{Value: 2, Percentage: true}},
+		{name: "negative", arg: "-2", err: ErrFireflyInvalidCommandAmount},
+		{name: "not a number", arg: "ten", err: ErrFireflyInvalidCommandAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseCommandAmount(tt.arg)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
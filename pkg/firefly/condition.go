@@ -0,0 +1,219 @@
+package firefly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+)
+
+// Condition restricts the transactions a configuration applies to. Every option set must match,
+// while All, Any and Not combine other conditions.
+type Condition struct {
+	Amount                *AmountRange  `json:"amount,omitempty"`
+	Tags                  *TagCondition `json:"tags,omitempty"`
+	Description           *Pattern      `json:"description,omitempty"`
+	Destination           *Pattern      `json:"destination,omitempty"`
+	SourceAccountIds      []string      `json:"source_account_ids,omitempty" ref:"account"`
+	DestinationAccountIds []string      `json:"destination_account_ids,omitempty" ref:"account"`
+	CategoryIds           []string      `json:"category_ids,omitempty" ref:"category"`
+	BudgetIds             []string      `json:"budget_ids,omitempty" ref:"budget"`
+	Currencies            []string      `json:"currencies,omitempty"`
+	Date                  *DateRange    `json:"date,omitempty"`
+	Weekdays              []Weekday     `json:"weekdays,omitempty"`
+	All                   []Condition   `json:"all,omitempty"`
+	Any                   []Condition   `json:"any,omitempty"`
+	Not                   *Condition    `json:"not,omitempty"`
+}
+
+// AmountRange matches amounts between Min and Max, both included.
+type AmountRange struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// TagCondition matches transactions having any, all or none of the given tags.
+type TagCondition struct {
+	Any  []string `json:"any,omitempty"`
+	All  []string `json:"all,omitempty"`
+	None []string `json:"none,omitempty"`
+}
+
+// DateRange matches transaction dates between From and Until, both included.
+type DateRange struct {
+	From  *Date `json:"from,omitempty"`
+	Until *Date `json:"until,omitempty"`
+}
+
+// Date is a day written as YYYY-MM-DD.
+type Date string
+
+const dateLayout = "2006-01-02"
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if _, err := time.Parse(dateLayout, s); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	*d = Date(s)
+
+	return nil
+}
+
+// Weekday is the lowercase English name of a day of the week.
+type Weekday string
+
+func (Weekday) Values() []string {
+	days := make([]string, 0, 7)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		days = append(days, strings.ToLower(d.String()))
+	}
+
+	return days
+}
+
+// Pattern is a regular expression matched against transaction fields.
+type Pattern struct {
+	*regexp.Regexp
+}
+
+func (p *Pattern) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	p.Regexp = re
+
+	return nil
+}
+
+func (p Pattern) MarshalJSON() ([]byte, error) {
+	if p.Regexp == nil {
+		return json.Marshal("")
+	}
+
+	return json.Marshal(p.String())
+}
+
+// MatchesMessage checks if any transaction of the message matches the condition.
+// A nil condition matches every message.
+func (c *Condition) MatchesMessage(msg WebhookMessage) bool {
+	if c == nil {
+		return true
+	}
+	content, ok := msg.Content.(WebhookMessageTransaction)
+
+	return ok && slices.ContainsFunc(content.Transactions, c.Matches)
+}
+
+// Matches checks if the transaction matches the condition.
+func (c Condition) Matches(t models.Transaction) bool {
+	if c.Amount != nil {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(t.Amount), 64)
+		if err != nil || c.Amount.Min != nil && amount < *c.Amount.Min || c.Amount.Max != nil && amount > *c.Amount.Max {
+			return false
+		}
+	}
+	if c.Tags != nil {
+		hasTag := func(tag string) bool { return slices.Contains(t.Tags, tag) }
+		if len(c.Tags.Any) > 0 && !slices.ContainsFunc(c.Tags.Any, hasTag) ||
+			slices.ContainsFunc(c.Tags.All, func(tag string) bool { return !hasTag(tag) }) ||
+			slices.ContainsFunc(c.Tags.None, hasTag) {
+			return false
+		}
+	}
+	if c.Description != nil && !c.Description.MatchString(t.Description) ||
+		c.Destination != nil && !c.Destination.MatchString(t.DestinationName) {
+		return false
+	}
+	if !matchesAny(c.SourceAccountIds, &t.SourceID) ||
+		!matchesAny(c.DestinationAccountIds, &t.DestinationID) ||
+		!matchesAny(c.CategoryIds, t.CategoryID) ||
+		!matchesAny(c.BudgetIds, t.BudgetID) ||
+		!matchesAny(c.Currencies, &t.CurrencyCode) {
+		return false
+	}
+	if c.Date != nil {
+		// Dates are compared in the time zone of the transaction
+		date := Date(t.Date.Format(dateLayout))
+		if c.Date.From != nil && date < *c.Date.From || c.Date.Until != nil && date > *c.Date.Until {
+			return false
+		}
+	}
+	if len(c.Weekdays) > 0 && !slices.Contains(c.Weekdays, Weekday(strings.ToLower(t.Date.Weekday().String()))) {
+		return false
+	}
+	if slices.ContainsFunc(c.All, func(sub Condition) bool { return !sub.Matches(t) }) ||
+		len(c.Any) > 0 && !slices.ContainsFunc(c.Any, func(sub Condition) bool { return sub.Matches(t) }) ||
+		c.Not != nil && c.Not.Matches(t) {
+		return false
+	}
+
+	return true
+}
+
+// matchesAny checks if the value is one of the given ones, when any is given.
+func matchesAny(values []string, value *string) bool {
+	return len(values) == 0 || value != nil && slices.Contains(values, *value)
+}
+
+// validate checks for options making the condition never match, reporting them as errors of the given field.
+func (c Condition) validate(field string) []error {
+	var errs []error
+	if c.Amount != nil {
+		if c.Amount.Min == nil && c.Amount.Max == nil {
+			errs = append(errs, newFieldError(field+".amount", "either min or max must be set"))
+		} else if c.Amount.Min != nil && c.Amount.Max != nil && *c.Amount.Min > *c.Amount.Max {
+			errs = append(errs, newFieldError(field+".amount", "min must not be greater than max"))
+		}
+	}
+	if c.Tags != nil {
+		for _, tag := range c.Tags.None {
+			if slices.Contains(c.Tags.All, tag) || len(c.Tags.Any) == 1 && c.Tags.Any[0] == tag {
+				errs = append(errs, newFieldError(field+".tags", "tag %q is both required and excluded", tag))
+			}
+		}
+	}
+	if c.Date != nil && c.Date.From != nil && c.Date.Until != nil && *c.Date.From > *c.Date.Until {
+		errs = append(errs, newFieldError(field+".date", "from must not be after until"))
+	}
+	for i, sub := range c.All {
+		errs = append(errs, sub.validate(fmt.Sprintf("%s.all[%d]", field, i))...)
+	}
+	if c.Any != nil && len(c.Any) == 0 {
+		errs = append(errs, newFieldError(field+".any", "must not be empty, the condition would never match"))
+	}
+	for i, sub := range c.Any {
+		errs = append(errs, sub.validate(fmt.Sprintf("%s.any[%d]", field, i))...)
+	}
+	if c.Not != nil {
+		errs = append(errs, c.Not.validate(field+".not")...)
+	}
+
+	return errs
+}
+
+// validateWhen checks the condition of the configuration, which can only match transactions.
+func (c BaseConfig) validateWhen() error {
+	if c.When == nil {
+		return nil
+	}
+	if c.Response != RESPONSE_TRANSACTIONS {
+		return newFieldError("when", "requires the %s response, the configuration would never apply", RESPONSE_TRANSACTIONS)
+	}
+
+	return errors.Join(c.When.validate("when")...)
+}
@@ -0,0 +1,142 @@
+package firefly
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionMatches(t *testing.T) {
+	category := "5"
+	// Saturday
+	date := time.Date(2024, 3, 16, 10, 0, 0, 0, time.UTC)
+	transaction := models.Transaction{
+		Amount:          "42.50",
+		Tags:            []string{"Satispay", "Food"},
+		Description:     "Pizza at Luigi's",
+		DestinationName: "Luigi's Pizzeria",
+		SourceID:        "1",
+		DestinationID:   "7",
+		CategoryID:      &category,
+		CurrencyCode:    "EUR",
+		Date:            date,
+	}
+
+	tests := []struct {
+		name     string
+		when     string
+		expected bool
+	}{
+		{name: "empty condition", when: `{}`, expected: true},
+		{name: "amount in range", when: `{"amount": {"min": 10, "max": 42.5}}`, expected: true},
+		{name: "amount below minimum", when: `{"amount": {"min": 50}}`, expected: false},
+		{name: "any tag", when: `{"tags": {"any": ["Cash", "Food"]}}`, expected: true},
+		{name: "missing one of all tags", when: `{"tags": {"all": ["Satispay", "Cashback"]}}`, expected: false},
+		{name: "excluded tag", when: `{"tags": {"none": ["Satispay"]}}`, expected: false},
+		{name: "description regex", when: `{"description": "(?i)^pizza"}`, expected: true},
+		{name: "destination regex", when: `{"destination": "Burger"}`, expected: false},
+		{name: "source account in list", when: `{"source_account_ids": ["1", "2"]}`, expected: true},
+		{name: "destination account not in list", when: `{"destination_account_ids": ["8"]}`, expected: false},
+		{name: "category in list", when: `{"category_ids": ["5"]}`, expected: true},
+		{name: "budget missing", when: `{"budget_ids": ["1"]}`, expected: false},
+		{name: "currency", when: `{"currencies": ["USD"]}`, expected: false},
+		{name: "date in range", when: `{"date": {"from": "2024-03-16", "until": "2024-03-31"}}`, expected: true},
+		{name: "date after range", when: `{"date": {"until": "2024-03-15"}}`, expected: false},
+		{name: "weekend", when: `{"weekdays": ["saturday", "sunday"]}`, expected: true},
+		{name: "all", when: `{"all": [{"currencies": ["EUR"]}, {"amount": {"max": 10}}]}`, expected: false},
+		{name: "any", when: `{"any": [{"currencies": ["USD"]}, {"tags": {"any": ["Food"]}}]}`, expected: true},
+		{name: "not", when: `{"not": {"weekdays": ["saturday"]}}`, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var condition Condition
+			require.NoError(t, json.Unmarshal([]byte(tt.when), &condition))
+			assert.Equal(t, tt.expected, condition.Matches(transaction))
+		})
+	}
+}
+
+func TestFindConfigWhen(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+  "balance_alert": [],
+  "mirror": [
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+      "base_url": "https://one.example.com", "api_key": "key",
+      "when": {"amount": {"min": 100}}
+    },
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def",
+      "base_url": "https://two.example.com", "api_key": "key"
+    }
+  ]
+}`))
+	require.NoError(t, err)
+
+	msg := WebhookMessage{
+		Trigger:  STORE_TRANSACTION,
+		Response: RESPONSE_TRANSACTIONS,
+		Content:  WebhookMessageTransaction{Transactions: []models.Transaction{{Amount: "20"}}},
+	}
+	found, err := config.FindConfig(Mirror, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "https://two.example.com", found.(MirrorConfig).BaseUrl)
+
+	msg.Content = WebhookMessageTransaction{Transactions: []models.Transaction{{Amount: "20"}, {Amount: "120"}}}
+	found, err = config.FindConfig(Mirror, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "https://one.example.com", found.(MirrorConfig).BaseUrl)
+}
+
+func TestFindConfigs(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+  "piggy_bank": [
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+      "type": "withdrawal", "source_account_id": "1", "piggy_bank_id": "2", "modulo_amount": 5
+    }
+  ],
+  "transfer": [
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+      "type": "withdrawal", "source_account_id": "1", "source_must_have_tag": "save",
+      "destination_account_id": "3", "link_type_id": "1", "category_id": "1", "fixed_amount": 10, "title": "Savings"
+    },
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+      "type": "withdrawal", "source_account_id": "1", "source_must_have_tag": "invest",
+      "destination_account_id": "4", "link_type_id": "1", "category_id": "1", "fixed_amount": 10, "title": "Investments",
+      "priority": 10, "stop": true, "when": {"amount": {"min": 100}}
+    }
+  ]
+}`))
+	require.NoError(t, err)
+
+	msg := WebhookMessage{
+		Trigger:  STORE_TRANSACTION,
+		Response: RESPONSE_TRANSACTIONS,
+		Content:  WebhookMessageTransaction{Transactions: []models.Transaction{{Type: "withdrawal", Amount: "20"}}},
+	}
+	paths := func(matches []ConfigMatch) []string {
+		var paths []string
+		for _, m := range matches {
+			paths = append(paths, m.String())
+		}
+		return paths
+	}
+	assert.Equal(t, []string{"piggy_bank[0]", "transfer[0]"}, paths(config.FindConfigs(msg)))
+	assert.Equal(t, []string{"transfer[0]"}, paths(config.FindConfigs(msg, Transfer)))
+
+	// The value with the highest priority runs first and stops the following ones
+	msg.Content = WebhookMessageTransaction{Transactions: []models.Transaction{{Type: "withdrawal", Amount: "120"}}}
+	assert.Equal(t, []string{"transfer[1]"}, paths(config.FindConfigs(msg)))
+
+	found, err := config.FindConfig(PiggyBank, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "2", found.(PiggyBankConfig).PiggyBankID)
+}
@@ -1,20 +1,34 @@
 package firefly
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"reflect"
 	"slices"
+	"strconv"
+	"strings"
 
-	"github.com/akyrey/firefly-iii-webhooks/pkg/assert"
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
 )
 
 // ConfigType is an enum listing all possible configuration types.
 type ConfigType string
 
 const (
-	SplitTicket ConfigType = "split_ticket"
-	Cashback    ConfigType = "cashback"
-	Transfer    ConfigType = "transfer"
+	SplitTicket   ConfigType = "split_ticket"
+	Cashback      ConfigType = "cashback"
+	Transfer      ConfigType = "transfer"
+	ForeignFee    ConfigType = "foreign_fee"
+	PiggyBank     ConfigType = "piggy_bank"
+	BalanceAlert  ConfigType = "balance_alert"
+	BudgetWarning ConfigType = "budget_warning"
+	Mirror        ConfigType = "mirror"
+	NotesCommands ConfigType = "notes_commands"
 )
 
 // Config holds configuration regarding Firefly webhooks.
@@ -23,6 +37,63 @@ type Config map[ConfigType][]ConfigValue
 type ConfigValue interface {
 	// AppliesTo checks if the configuration applies to the given message.
 	AppliesTo(msg WebhookMessage) bool
+	// Validate checks for values making the configuration fail or never apply.
+	Validate() error
+	// shadows checks if the configuration applies to every message the other one applies to.
+	shadows(other ConfigValue) bool
+	// Base returns the options shared by every configuration value.
+	Base() BaseConfig
+}
+
+// BaseConfig holds the options shared by every configuration value.
+// The secret can be read from SecretFile instead, e.g. a Docker secret.
+// When restricts the transactions the configuration applies to.
+// Values applying to the same message run from the highest Priority, and Stop skips the ones following it.
+// Enabled, ValidFrom, ValidUntil and Windows restrict when the configuration is active.
+type BaseConfig struct {
+	Trigger    WebhookTrigger  `json:"trigger"`
+	Response   WebhookResponse `json:"response"`
+	Secret     Secret          `json:"secret,omitempty"`
+	SecretFile string          `json:"secret_file,omitempty"`
+	When       *Condition      `json:"when,omitempty"`
+	Priority   int             `json:"priority,omitempty"`
+	Stop       bool            `json:"stop,omitempty"`
+	Enabled    *bool           `json:"enabled,omitempty"`
+	ValidFrom  *Date           `json:"valid_from,omitempty"`
+	ValidUntil *Date           `json:"valid_until,omitempty"`
+	Windows    []Window        `json:"windows,omitempty"`
+}
+
+// Base returns the options shared by every configuration value.
+func (c BaseConfig) Base() BaseConfig {
+	return c
+}
+
+// sameWebhook checks if both configurations are triggered by the same kind of webhook.
+func (c BaseConfig) sameWebhook(other ConfigValue) bool {
+	return c.Trigger == other.Base().Trigger && c.Response == other.Base().Response
+}
+
+// validateResponse checks the configured response is the one the action needs.
+func (c BaseConfig) validateResponse(response WebhookResponse) error {
+	if c.Response != response {
+		return newFieldError("response", "must be %s, the configuration would never apply", response)
+	}
+
+	return nil
+}
+
+// configValueTypes maps each ConfigType to the type of its configuration values.
+var configValueTypes = map[ConfigType]reflect.Type{
+	SplitTicket:   reflect.TypeFor[SplitTicketConfig](),
+	Cashback:      reflect.TypeFor[CashbackConfig](),
+	Transfer:      reflect.TypeFor[TransferConfig](),
+	ForeignFee:    reflect.TypeFor[ForeignFeeConfig](),
+	PiggyBank:     reflect.TypeFor[PiggyBankConfig](),
+	BalanceAlert:  reflect.TypeFor[BalanceAlertConfig](),
+	BudgetWarning: reflect.TypeFor[BudgetWarningConfig](),
+	Mirror:        reflect.TypeFor[MirrorConfig](),
+	NotesCommands: reflect.TypeFor[NotesCommandsConfig](),
 }
 
 // UnmarshalJSON unmarshals the JSON configuration file into the Config struct.
@@ -35,73 +106,110 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	for t, list := range config {
-		switch t {
-		case SplitTicket:
-			var splitTicketList []ConfigValue
-			for _, raw := range list {
-				var splitTicket SplitTicketConfig
-				if err := json.Unmarshal(raw, &splitTicket); err != nil {
-					return err
-				}
-				splitTicketList = append(splitTicketList, splitTicket)
-			}
-			(*c)[t] = splitTicketList
-		case Cashback:
-			var cashbackList []ConfigValue
-			for _, raw := range list {
-				var cashback CashbackConfig
-				if err := json.Unmarshal(raw, &cashback); err != nil {
-					return err
-				}
-				cashbackList = append(cashbackList, cashback)
-			}
-			(*c)[t] = cashbackList
-		case Transfer:
-			var transferList []ConfigValue
-			for _, raw := range list {
-				var cashback TransferConfig
-				if err := json.Unmarshal(raw, &cashback); err != nil {
-					return err
-				}
-				transferList = append(transferList, cashback)
+		valueType, ok := configValueTypes[t]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFireflyUnknownConfigType, t)
+		}
+		var values []ConfigValue
+		for _, raw := range list {
+			value := reflect.New(valueType)
+			if err := json.Unmarshal(raw, value.Interface()); err != nil {
+				return err
 			}
-			(*c)[t] = transferList
+			values = append(values, value.Elem().Interface().(ConfigValue))
 		}
+		(*c)[t] = values
 	}
 	return nil
 }
 
-// FindConfig finds the configuration that applies to the given message.
+// ConfigMatch is a configuration value applying to a message, with its position in the configuration.
+// Inactive holds the reason why the value isn't active at the date of the message, if it isn't.
+type ConfigMatch struct {
+	Type     ConfigType
+	Index    int
+	Value    ConfigValue
+	Inactive string
+}
+
+// String returns the path of the value in the configuration, e.g. "transfer[1]".
+func (m ConfigMatch) String() string {
+	return fmt.Sprintf("%s[%d]", m.Type, m.Index)
+}
+
+// FindConfigs finds the configurations of the given types, or of every type when none is given, that apply
+// to the given message and match their when condition, from the highest priority. Values with the same priority
+// keep the order of the configuration, and the ones following an active value with stop are left out.
+// Values inactive at the date of the message are returned as well, with the reason why they are inactive.
+func (c *Config) FindConfigs(msg WebhookMessage, types ...ConfigType) []ConfigMatch {
+	if len(types) == 0 {
+		types = sortedKeys(*c)
+	}
+	date := messageDate(msg)
+	var matches []ConfigMatch
+	for _, t := range types {
+		for i, value := range (*c)[t] {
+			if value.AppliesTo(msg) && value.Base().When.MatchesMessage(msg) {
+				_, reason := value.Base().ActiveAt(date)
+				matches = append(matches, ConfigMatch{Type: t, Index: i, Value: value, Inactive: reason})
+			}
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b ConfigMatch) int {
+		return cmp.Compare(b.Value.Base().Priority, a.Value.Base().Priority)
+	})
+	if i := slices.IndexFunc(matches, func(m ConfigMatch) bool { return m.Inactive == "" && m.Value.Base().Stop }); i != -1 {
+		matches = matches[:i+1]
+	}
+
+	return matches
+}
+
+// FindConfig finds the active configuration of the given type that applies first to the given message.
 func (c *Config) FindConfig(t ConfigType, msg WebhookMessage) (ConfigValue, error) {
-	list, ok := (*c)[t]
-	if !ok {
+	matches := c.FindConfigs(msg, t)
+	i := slices.IndexFunc(matches, func(m ConfigMatch) bool { return m.Inactive == "" })
+	if i == -1 {
 		return nil, ErrFireflyConfigNotFound
 	}
-	cIdx := slices.IndexFunc(
-		list,
-		func(c ConfigValue) bool {
-			return c.AppliesTo(msg)
-		},
-	)
-	if cIdx == -1 {
-		return nil, ErrFireflyConfigNotFound
+
+	return matches[i].Value, nil
+}
+
+// WithEnabled returns a copy of the configuration with the value at the given index of the action enabled or
+// disabled, leaving the configuration itself untouched so it can be swapped while requests use it.
+func (c *Config) WithEnabled(t ConfigType, index int, enabled bool) (*Config, error) {
+	values := (*c)[t]
+	if index < 0 || index >= len(values) {
+		return nil, fmt.Errorf("%w: %s[%d]", ErrFireflyConfigNotFound, t, index)
+	}
+	value := reflect.New(reflect.TypeOf(values[index])).Elem()
+	value.Set(reflect.ValueOf(values[index]))
+	value.FieldByName("BaseConfig").FieldByName("Enabled").Set(reflect.ValueOf(&enabled))
+
+	config := make(Config, len(*c))
+	for k, v := range *c {
+		config[k] = v
 	}
+	config[t] = slices.Clone(values)
+	config[t][index] = value.Interface().(ConfigValue)
 
-	return list[cIdx], nil
+	return &config, nil
 }
 
 // SplitTicketConfig holds configuration for splitting a transaction.
 type SplitTicketConfig struct {
-	Trigger                          WebhookTrigger  `json:"trigger"`
-	Response                         WebhookResponse `json:"response"`
-	Secret                           string          `json:"secret"`
+	BaseConfig
 	Type                             TransactionType `json:"type"`
-	LinkTypeId                       string          `json:"link_type_id"`
-	SourceAccountId                  string          `json:"source_account_id"`
-	DestinationAccountId             string          `json:"destination_account_id"`
-	DestinationCurrencyId            string          `json:"destination_currency_id"`
-	DestinationCurrencyDecimalPlaces int             `json:"destination_currency_decimal_places"`
+	LinkTypeId                       string          `json:"link_type_id" ref:"link_type"`
+	SourceAccountId                  string          `json:"source_account_id" ref:"account"`
+	DestinationAccountId             string          `json:"destination_account_id" ref:"account"`
+	DestinationCurrencyId            string          `json:"destination_currency_id,omitempty" ref:"currency"`
+	DestinationCurrencyDecimalPlaces *int            `json:"destination_currency_decimal_places,omitempty"`
 	SplitAmount                      float64         `json:"split_amount"`
+	// Title and Notes of the created transaction default to the ones of the split transaction.
+	Title *Template `json:"title,omitempty"`
+	Notes *Template `json:"notes,omitempty"`
 }
 
 // AppliesTo checks if the configuration applies to the given message.
@@ -112,22 +220,48 @@ func (c SplitTicketConfig) AppliesTo(msg WebhookMessage) bool {
 		c.SourceAccountId != c.DestinationAccountId
 }
 
+// Validate checks for values making the configuration fail or never apply.
+func (c SplitTicketConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	if c.Type != WITHDRAWAL {
+		errs = append(errs, newFieldError("type", "must be %s, the configuration would never apply", WITHDRAWAL))
+	}
+	if c.SourceAccountId == c.DestinationAccountId {
+		errs = append(errs, newFieldError("destination_account_id", "must differ from source_account_id, the configuration would never apply"))
+	}
+	if c.SplitAmount <= 0 {
+		errs = append(errs, newFieldError("split_amount", "must be greater than 0"))
+	}
+	if c.DestinationCurrencyDecimalPlaces != nil && *c.DestinationCurrencyDecimalPlaces < 0 {
+		errs = append(errs, newFieldError("destination_currency_decimal_places", "must not be negative"))
+	}
+
+	errs = append(errs, c.Title.validate("title", c), c.Notes.validate("notes", c))
+
+	return errors.Join(errs...)
+}
+
+func (c SplitTicketConfig) shadows(other ConfigValue) bool {
+	_, ok := other.(SplitTicketConfig)
+	return ok && c.sameWebhook(other) && c.Type == WITHDRAWAL && c.SourceAccountId != c.DestinationAccountId
+}
+
 // CashbackConfig holds configuration for creating a cashback transaction.
 type CashbackConfig struct {
-	Trigger                          WebhookTrigger  `json:"trigger"`
-	Response                         WebhookResponse `json:"response"`
-	Secret                           string          `json:"secret"`
+	BaseConfig
 	Type                             TransactionType `json:"type"`
-	Title                            string          `json:"title"`
+	Title                            Template        `json:"title"`
+	Notes                            *Template       `json:"notes,omitempty"`
 	SourceMustHaveTag                string          `json:"source_must_have_tag"`
-	LinkTypeId                       string          `json:"link_type_id"`
-	SourceAccountId                  string          `json:"source_account_id"`
-	DepositSourceAccountId           string          `json:"deposit_source_account_id"`
-	DestinationAccountId             string          `json:"destination_account_id"`
+	LinkTypeId                       string          `json:"link_type_id" ref:"link_type"`
+	SourceAccountId                  string          `json:"source_account_id" ref:"account"`
+	DepositSourceAccountId           string          `json:"deposit_source_account_id" ref:"account"`
+	DestinationAccountId             string          `json:"destination_account_id" ref:"account"`
 	Amount                           float64         `json:"amount"`
-	CategoryID                       string          `json:"category_id"`
-	DestinationCurrencyId            string          `json:"destination_currency_id"`
-	DestinationCurrencyDecimalPlaces int             `json:"destination_currency_decimal_places"`
+	CategoryID                       string          `json:"category_id" ref:"category"`
+	DestinationCurrencyId            string          `json:"destination_currency_id,omitempty" ref:"currency"`
+	DestinationCurrencyDecimalPlaces *int            `json:"destination_currency_decimal_places,omitempty"`
 }
 
 // AppliesTo checks if the configuration applies to the given message.
@@ -137,22 +271,48 @@ func (c CashbackConfig) AppliesTo(msg WebhookMessage) bool {
 		c.Type == WITHDRAWAL
 }
 
+// Validate checks for values making the configuration fail or never apply.
+func (c CashbackConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	if c.Type != WITHDRAWAL {
+		errs = append(errs, newFieldError("type", "must be %s, the configuration would never apply", WITHDRAWAL))
+	}
+	if c.SourceMustHaveTag == "" {
+		errs = append(errs, newFieldError("source_must_have_tag", "must not be empty, no transaction would match"))
+	}
+	if c.Amount <= 0 {
+		errs = append(errs, newFieldError("amount", "must be greater than 0"))
+	}
+	if c.DestinationCurrencyDecimalPlaces != nil && *c.DestinationCurrencyDecimalPlaces < 0 {
+		errs = append(errs, newFieldError("destination_currency_decimal_places", "must not be negative"))
+	}
+
+	errs = append(errs, c.Title.validate("title", c), c.Notes.validate("notes", c))
+
+	return errors.Join(errs...)
+}
+
+func (c CashbackConfig) shadows(other ConfigValue) bool {
+	_, ok := other.(CashbackConfig)
+	return ok && c.sameWebhook(other) && c.Type == WITHDRAWAL
+}
+
 // TransferConfig holds configuration for creating a transfer transaction.
 type TransferConfig struct {
+	BaseConfig
 	FixedAmount                      *float64        `json:"fixed_amount,omitempty"`
 	ModuloAmount                     *float64        `json:"modulo_amount,omitempty"`
-	LinkTypeId                       string          `json:"link_type_id"`
-	Secret                           string          `json:"secret"`
+	LinkTypeId                       string          `json:"link_type_id" ref:"link_type"`
 	Type                             TransactionType `json:"type"`
-	Title                            string          `json:"title"`
+	Title                            Template        `json:"title"`
+	Notes                            *Template       `json:"notes,omitempty"`
 	SourceMustHaveTag                string          `json:"source_must_have_tag"`
-	Trigger                          WebhookTrigger  `json:"trigger"`
-	Response                         WebhookResponse `json:"response"`
-	SourceAccountId                  string          `json:"source_account_id"`
-	DestinationAccountId             string          `json:"destination_account_id"`
-	CategoryID                       string          `json:"category_id"`
-	DestinationCurrencyId            string          `json:"destination_currency_id"`
-	DestinationCurrencyDecimalPlaces int             `json:"destination_currency_decimal_places"`
+	SourceAccountId                  string          `json:"source_account_id" ref:"account"`
+	DestinationAccountId             string          `json:"destination_account_id" ref:"account"`
+	CategoryID                       string          `json:"category_id" ref:"category"`
+	DestinationCurrencyId            string          `json:"destination_currency_id,omitempty" ref:"currency"`
+	DestinationCurrencyDecimalPlaces *int            `json:"destination_currency_decimal_places,omitempty"`
 }
 
 // AppliesTo checks if the configuration applies to the given message.
@@ -165,20 +325,448 @@ func (c TransferConfig) AppliesTo(msg WebhookMessage) bool {
 		c.Type == TransactionType(content.Transactions[0].Type)
 }
 
-// ReadConfig reads the configuration from a JSON file.
-func ReadConfig(file string) *Config {
-	configFile, err := os.Open(file)
-	assert.NoError(err, "Firefly configuration file should always be provided")
-	defer func(configFile *os.File) {
-		_ = configFile.Close()
-	}(configFile)
+// Validate checks for values making the configuration fail or never apply.
+func (c TransferConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	switch {
+	case c.FixedAmount != nil && c.ModuloAmount != nil:
+		errs = append(errs, newFieldError("modulo_amount", "must not be set together with fixed_amount"))
+	case c.FixedAmount == nil && c.ModuloAmount == nil:
+		errs = append(errs, newFieldError("fixed_amount", "either fixed_amount or modulo_amount must be set"))
+	case c.FixedAmount != nil && *c.FixedAmount <= 0:
+		errs = append(errs, newFieldError("fixed_amount", "must be greater than 0"))
+	case c.ModuloAmount != nil && *c.ModuloAmount <= 0:
+		errs = append(errs, newFieldError("modulo_amount", "must be greater than 0"))
+	}
+	if c.SourceMustHaveTag == "" {
+		errs = append(errs, newFieldError("source_must_have_tag", "must not be empty, no transaction would match"))
+	}
+	if c.SourceAccountId == c.DestinationAccountId {
+		errs = append(errs, newFieldError("destination_account_id", "must differ from source_account_id"))
+	}
+	if c.DestinationCurrencyDecimalPlaces != nil && *c.DestinationCurrencyDecimalPlaces < 0 {
+		errs = append(errs, newFieldError("destination_currency_decimal_places", "must not be negative"))
+	}
+
+	errs = append(errs, c.Title.validate("title", c), c.Notes.validate("notes", c))
+
+	return errors.Join(errs...)
+}
+
+func (c TransferConfig) shadows(other ConfigValue) bool {
+	o, ok := other.(TransferConfig)
+	return ok && c.sameWebhook(other) && c.Type == o.Type
+}
+
+// ForeignFeeConfig holds configuration for creating a fee transaction on foreign currency transactions.
+type ForeignFeeConfig struct {
+	BaseConfig
+	Type                 TransactionType `json:"type"`
+	Title                Template        `json:"title"`
+	Notes                *Template       `json:"notes,omitempty"`
+	LinkTypeId           string          `json:"link_type_id" ref:"link_type"`
+	SourceAccountId      string          `json:"source_account_id" ref:"account"`
+	DestinationAccountId string          `json:"destination_account_id" ref:"account"`
+	CategoryID           string          `json:"category_id" ref:"category"`
+	// Currencies lists currency codes always charged with a fee, even when they match the account currency.
+	Currencies  []string `json:"currencies,omitempty"`
+	Percentage  float64  `json:"percentage,omitempty"`
+	FixedAmount float64  `json:"fixed_amount,omitempty"`
+}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c ForeignFeeConfig) AppliesTo(msg WebhookMessage) bool {
+	content, ok := msg.Content.(WebhookMessageTransaction)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok &&
+		len(content.Transactions) > 0 &&
+		c.Type == TransactionType(content.Transactions[0].Type)
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c ForeignFeeConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	if c.Percentage < 0 {
+		errs = append(errs, newFieldError("percentage", "must not be negative"))
+	}
+	if c.FixedAmount < 0 {
+		errs = append(errs, newFieldError("fixed_amount", "must not be negative"))
+	}
+	if c.Percentage == 0 && c.FixedAmount == 0 {
+		errs = append(errs, newFieldError("percentage", "either percentage or fixed_amount must be greater than 0"))
+	}
+	if c.SourceAccountId == c.DestinationAccountId {
+		errs = append(errs, newFieldError("destination_account_id", "must differ from source_account_id"))
+	}
+
+	errs = append(errs, c.Title.validate("title", c), c.Notes.validate("notes", c))
+
+	return errors.Join(errs...)
+}
+
+func (c ForeignFeeConfig) shadows(other ConfigValue) bool {
+	o, ok := other.(ForeignFeeConfig)
+	return ok && c.sameWebhook(other) && c.Type == o.Type
+}
+
+// IsForeign checks if the transaction has been made in a currency that should be charged with a fee.
+func (c ForeignFeeConfig) IsForeign(t models.Transaction) bool {
+	currencyCode := t.CurrencyCode
+	if t.ForeignCurrencyCode != nil && *t.ForeignCurrencyCode != "" {
+		if *t.ForeignCurrencyCode != t.CurrencyCode {
+			return true
+		}
+		currencyCode = *t.ForeignCurrencyCode
+	}
+
+	return slices.Contains(c.Currencies, currencyCode)
+}
+
+// PiggyBankConfig holds configuration for adding or removing money from a piggy bank.
+type PiggyBankConfig struct {
+	BaseConfig
+	FixedAmount       *float64        `json:"fixed_amount,omitempty"`
+	ModuloAmount      *float64        `json:"modulo_amount,omitempty"`
+	Percentage        *float64        `json:"percentage,omitempty"`
+	Type              TransactionType `json:"type"`
+	PiggyBankID       string          `json:"piggy_bank_id"`
+	SourceAccountId   string          `json:"source_account_id" ref:"account"`
+	SourceMustHaveTag string          `json:"source_must_have_tag,omitempty"`
+	// Remove money from the piggy bank instead of adding it.
+	Remove bool `json:"remove,omitempty"`
+}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c PiggyBankConfig) AppliesTo(msg WebhookMessage) bool {
+	content, ok := msg.Content.(WebhookMessageTransaction)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok &&
+		len(content.Transactions) > 0 &&
+		c.Type == TransactionType(content.Transactions[0].Type)
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c PiggyBankConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	amounts := map[string]*float64{
+		"fixed_amount":  c.FixedAmount,
+		"modulo_amount": c.ModuloAmount,
+		"percentage":    c.Percentage,
+	}
+	set := 0
+	for _, field := range sortedKeys(amounts) {
+		if amounts[field] == nil {
+			continue
+		}
+		set++
+		if *amounts[field] <= 0 {
+			errs = append(errs, newFieldError(field, "must be greater than 0"))
+		}
+	}
+	if set != 1 {
+		errs = append(errs, newFieldError("fixed_amount", "exactly one of fixed_amount, modulo_amount or percentage must be set"))
+	}
 
-	var config Config
-	jsonParser := json.NewDecoder(configFile)
-	err = jsonParser.Decode(&config)
-	assert.NoError(err, "Unable to parse Firefly configuration file")
+	return errors.Join(errs...)
+}
 
-	return &config
+func (c PiggyBankConfig) shadows(other ConfigValue) bool {
+	o, ok := other.(PiggyBankConfig)
+	return ok && c.sameWebhook(other) && c.Type == o.Type
+}
+
+// BalanceAlertConfig holds configuration for alerting when an account balance crosses a threshold.
+type BalanceAlertConfig struct {
+	BaseConfig
+	LowThreshold  *float64 `json:"low_threshold,omitempty"`
+	HighThreshold *float64 `json:"high_threshold,omitempty"`
+	AccountId     string   `json:"account_id" ref:"account"`
+}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c BalanceAlertConfig) AppliesTo(msg WebhookMessage) bool {
+	content, ok := msg.Content.(WebhookMessageAccounts)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok &&
+		slices.ContainsFunc(content, func(a models.Account) bool {
+			return a.ID == c.AccountId
+		})
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c BalanceAlertConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_ACCOUNTS))
+	if c.LowThreshold == nil && c.HighThreshold == nil {
+		errs = append(errs, newFieldError("low_threshold", "either low_threshold or high_threshold must be set"))
+	}
+	if c.LowThreshold != nil && c.HighThreshold != nil && *c.LowThreshold > *c.HighThreshold {
+		errs = append(errs, newFieldError("high_threshold", "must be greater than low_threshold"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c BalanceAlertConfig) shadows(other ConfigValue) bool {
+	o, ok := other.(BalanceAlertConfig)
+	return ok && c.sameWebhook(other) && c.AccountId == o.AccountId
+}
+
+// BalanceState is the position of an account balance with respect to the configured thresholds.
+type BalanceState string
+
+const (
+	BALANCE_LOW    BalanceState = "low"
+	BALANCE_NORMAL BalanceState = "normal"
+	BALANCE_HIGH   BalanceState = "high"
+)
+
+// State returns the position of the given balance with respect to the configured thresholds.
+func (c BalanceAlertConfig) State(balance float64) BalanceState {
+	if c.LowThreshold != nil && balance < *c.LowThreshold {
+		return BALANCE_LOW
+	}
+	if c.HighThreshold != nil && balance > *c.HighThreshold {
+		return BALANCE_HIGH
+	}
+
+	return BALANCE_NORMAL
+}
+
+// BudgetWarningConfig holds configuration for warning when the budget of a withdrawal is being overspent.
+type BudgetWarningConfig struct {
+	BaseConfig
+	// BudgetIds limits the warnings to the given budgets, every budget is checked when empty.
+	BudgetIds []string `json:"budget_ids,omitempty" ref:"budget"`
+	// Thresholds are the percentages of the budget limit to warn about, defaults to DefaultBudgetThresholds.
+	Thresholds []float64 `json:"thresholds,omitempty"`
+}
+
+// DefaultBudgetThresholds are the percentages of a budget limit used when no threshold is configured.
+var DefaultBudgetThresholds = []float64{75, 90, 100}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c BudgetWarningConfig) AppliesTo(msg WebhookMessage) bool {
+	content, ok := msg.Content.(WebhookMessageTransaction)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok &&
+		slices.ContainsFunc(content.Transactions, c.Watches)
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c BudgetWarningConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	for i, threshold := range c.Thresholds {
+		if threshold <= 0 {
+			errs = append(errs, newFieldError(fmt.Sprintf("thresholds[%d]", i), "must be greater than 0"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c BudgetWarningConfig) shadows(other ConfigValue) bool {
+	o, ok := other.(BudgetWarningConfig)
+	if !ok || !c.sameWebhook(other) {
+		return false
+	}
+	if len(c.BudgetIds) == 0 {
+		return true
+	}
+
+	return len(o.BudgetIds) > 0 && !slices.ContainsFunc(o.BudgetIds, func(id string) bool {
+		return !slices.Contains(c.BudgetIds, id)
+	})
+}
+
+// Watches checks if the budget of the given transaction should be checked.
+func (c BudgetWarningConfig) Watches(t models.Transaction) bool {
+	return TransactionType(t.Type) == WITHDRAWAL &&
+		t.BudgetID != nil &&
+		*t.BudgetID != "" &&
+		(len(c.BudgetIds) == 0 || slices.Contains(c.BudgetIds, *t.BudgetID))
+}
+
+// ReachedThreshold returns the highest threshold reached by the given percentage of the budget limit used,
+// or 0 when none has been reached.
+func (c BudgetWarningConfig) ReachedThreshold(percentage float64) float64 {
+	thresholds := c.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = DefaultBudgetThresholds
+	}
+
+	reached := 0.0
+	for _, threshold := range thresholds {
+		if percentage >= threshold && threshold > reached {
+			reached = threshold
+		}
+	}
+
+	return reached
+}
+
+// MirrorConfig holds configuration for copying transactions into another Firefly III instance.
+type MirrorConfig struct {
+	BaseConfig
+	// Mappings from ids of this instance to ids of the mirror instance. Accounts and categories
+	// without a mapping are referenced by name, budgets without a mapping are dropped.
+	AccountMapping  map[string]string `json:"account_mapping,omitempty"`
+	CategoryMapping map[string]string `json:"category_mapping,omitempty"`
+	BudgetMapping   map[string]string `json:"budget_mapping,omitempty"`
+	CurrencyMapping map[string]string `json:"currency_mapping,omitempty"`
+	MustHaveTag     string            `json:"must_have_tag,omitempty"`
+	BaseUrl         string            `json:"base_url"`
+	ApiKey          Secret            `json:"api_key,omitempty"`
+	ApiKeyFile      string            `json:"api_key_file,omitempty"`
+}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c MirrorConfig) AppliesTo(msg WebhookMessage) bool {
+	_, ok := msg.Content.(WebhookMessageTransaction)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c MirrorConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	u, err := url.Parse(c.BaseUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, newFieldError("base_url", "must be an http or https URL"))
+	} else if strings.HasSuffix(c.BaseUrl, "/") {
+		errs = append(errs, newFieldError("base_url", "must not end with /"))
+	}
+	if strings.TrimSpace(string(c.ApiKey)) == "" {
+		errs = append(errs, newFieldError("api_key", "must be set, directly or with api_key_file"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c MirrorConfig) shadows(other ConfigValue) bool {
+	_, ok := other.(MirrorConfig)
+	return ok && c.sameWebhook(other)
+}
+
+// Mirrors checks if the given transactions should be copied into the mirror instance.
+func (c MirrorConfig) Mirrors(content WebhookMessageTransaction) bool {
+	return len(content.Transactions) > 0 &&
+		(c.MustHaveTag == "" || slices.ContainsFunc(content.Transactions, func(t models.Transaction) bool {
+			return slices.Contains(t.Tags, c.MustHaveTag)
+		}))
+}
+
+// LogValue hides the mirror instance api key from logs.
+func (c MirrorConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("trigger", string(c.Trigger)),
+		slog.String("response", string(c.Response)),
+		slog.String("must_have_tag", c.MustHaveTag),
+		slog.String("base_url", c.BaseUrl),
+	)
+}
+
+// NotesCommandsConfig holds configuration for running the commands found in transaction notes.
+// Each command runs the action with the same name, overriding its configuration with the command arguments.
+type NotesCommandsConfig struct {
+	BaseConfig
+	// Accounts maps the aliases used in commands to account ids or names.
+	Accounts map[string]string `json:"accounts,omitempty" ref:"account"`
+	// Annotate marks the processed commands in the notes instead of removing them.
+	Annotate bool `json:"annotate,omitempty"`
+}
+
+// AppliesTo checks if the configuration applies to the given message.
+func (c NotesCommandsConfig) AppliesTo(msg WebhookMessage) bool {
+	content, ok := msg.Content.(WebhookMessageTransaction)
+	return c.Trigger == msg.Trigger &&
+		c.Response == msg.Response &&
+		ok &&
+		slices.ContainsFunc(content.Transactions, func(t models.Transaction) bool {
+			return HasCommands(t.Notes)
+		})
+}
+
+// Validate checks for values making the configuration fail or never apply.
+func (c NotesCommandsConfig) Validate() error {
+	var errs []error
+	errs = append(errs, c.validateResponse(RESPONSE_TRANSACTIONS))
+	for _, alias := range sortedKeys(c.Accounts) {
+		if c.Accounts[alias] == "" {
+			errs = append(errs, newFieldError(fmt.Sprintf("accounts.%s", alias), "must not be empty"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c NotesCommandsConfig) shadows(other ConfigValue) bool {
+	_, ok := other.(NotesCommandsConfig)
+	return ok && c.sameWebhook(other)
+}
+
+// AccountID returns the account id for the given alias. Account ids are accepted as they are.
+func (c NotesCommandsConfig) AccountID(alias string) (string, error) {
+	if id, ok := c.Accounts[alias]; ok {
+		return id, nil
+	}
+	if _, err := strconv.Atoi(alias); err == nil {
+		return alias, nil
+	}
+
+	return "", ErrFireflyUnknownAccountAlias
+}
+
+// LoadConfig reads and validates the configuration from a file, a directory or a glob pattern, merging the
+// files found as listed by ConfigFiles. The format of each file is picked from its extension: .yaml and .yml files
+// are read as YAML, .toml files as TOML and any other file as JSON.
+// Validation errors are returned as ConfigErrors, listing every problem found with its file.
+func LoadConfig(path string, opts ...LoadOption) (*Config, error) {
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make([]*configNode, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		root, err := parseConfigNode(data, ConfigFormatOf(file))
+		var configErrors ConfigErrors
+		if errors.As(err, &configErrors) {
+			for i := range configErrors {
+				configErrors[i].File = file
+			}
+			return nil, configErrors
+		} else if err != nil {
+			return nil, err
+		}
+		root.setFile(file)
+		roots = append(roots, root)
+	}
+
+	config, err := decodeConfigNodes(roots, opts...)
+	var configErrors ConfigErrors
+	if errors.As(err, &configErrors) && len(files) == 1 {
+		// Errors without a position, e.g. of the whole document, belong to the only file as well
+		for i := range configErrors {
+			configErrors[i].File = files[0]
+		}
+	}
+
+	return config, err
 }
 
 type TransactionType string
@@ -189,5 +777,10 @@ const (
 	TRANSFER   TransactionType = "transfer"
 )
 
+// Values lists the valid transaction types.
+func (TransactionType) Values() []string {
+	return []string{string(WITHDRAWAL), string(DEPOSIT), string(TRANSFER)}
+}
+
 // WEBHOOK_TAG_PREFIX is the prefix used for all tags we are going to attach to transactions.
 const WEBHOOK_TAG_PREFIX = "Webhook:"
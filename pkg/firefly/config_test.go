@@ -0,0 +1,98 @@
+package firefly
+
+import (
+	"testing"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForeignFeeIsForeign(t *testing.T) {
+	usd := "USD"
+	eur := "EUR"
+	empty := ""
+	tests := []struct {
+		name        string
+		currencies  []string
+		transaction models.Transaction
+		expected    bool
+	}{
+		{
+			name:        "same currency without foreign amount",
+			transaction: models.Transaction{CurrencyCode: "EUR"},
+			expected:    false,
+		},
+		{
+			name:        "empty foreign currency",
+			transaction: models.Transaction{CurrencyCode: "EUR", ForeignCurrencyCode: &empty},
+			expected:    false,
+		},
+		{
+			name:        "foreign currency different from account currency",
+			transaction: models.Transaction{CurrencyCode: "EUR", ForeignCurrencyCode: &usd},
+			expected:    true,
+		},
+		{
+			name:        "foreign currency equal to account currency",
+			transaction: models.Transaction{CurrencyCode: "EUR", ForeignCurrencyCode: &eur},
+			expected:    false,
+		},
+		{
+			name:        "account currency in configured list",
+			currencies:  []string{"GBP", "EUR"},
+			transaction: models.Transaction{CurrencyCode: "EUR"},
+			expected:    true,
+		},
+		{
+			name:        "account currency not in configured list",
+			currencies:  []string{"GBP"},
+			transaction: models.Transaction{CurrencyCode: "EUR"},
+			expected:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ForeignFeeConfig{Currencies: tt.currencies}
+			assert.Equal(t, tt.expected, config.IsForeign(tt.transaction))
+		})
+	}
+}
+
+func TestBudgetWarningReachedThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []float64
+		percentage float64
+		expected   float64
+	}{
+		{
+			name:       "default thresholds not reached",
+			percentage: 50,
+			expected:   0,
+		},
+		{
+			name:       "default thresholds first reached",
+			percentage: 80,
+			expected:   75,
+		},
+		{
+			name:       "default thresholds overspent",
+			percentage: 130,
+			expected:   100,
+		},
+		{
+			name:       "custom thresholds unordered",
+			thresholds: []float64{100, 50},
+			percentage: 60,
+			expected:   50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := BudgetWarningConfig{Thresholds: tt.thresholds}
+			assert.Equal(t, tt.expected, config.ReachedThreshold(tt.percentage))
+		})
+	}
+}
@@ -3,8 +3,16 @@ package firefly
 import "errors"
 
 var (
-	ErrFireflyConfigNotFound   = errors.New("configuration not found")
-	ErrFireflyEmptyApiKey      = errors.New("api key cannot be empty")
-	ErrFireflyInvalidSignature = errors.New("invalid signature")
-	ErrFireflyInvalidSecret    = errors.New("invalid signature secret")
+	ErrFireflyConfigNotFound       = errors.New("configuration not found")
+	ErrFireflyEmptyApiKey          = errors.New("api key cannot be empty")
+	ErrFireflyInvalidSignature     = errors.New("invalid signature")
+	ErrFireflyInvalidSecret        = errors.New("invalid signature secret")
+	ErrFireflyInvalidCommand       = errors.New("invalid command arguments")
+	ErrFireflyInvalidCommandAmount = errors.New("invalid command amount")
+	ErrFireflyUnknownAccountAlias  = errors.New("unknown account alias")
+	ErrFireflyUnknownConfigType    = errors.New("unknown configuration type")
+	ErrFireflyUnknownConfigFormat  = errors.New("unknown configuration format")
+	ErrFireflyNameNotFound         = errors.New("name not found")
+	ErrFireflyAmbiguousName        = errors.New("ambiguous name")
+	ErrFireflyIDNotFound           = errors.New("id not found")
 )
@@ -0,0 +1,415 @@
+package firefly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the format a configuration file is written in.
+type ConfigFormat string
+
+const (
+	JSON ConfigFormat = "json"
+	YAML ConfigFormat = "yaml"
+	TOML ConfigFormat = "toml"
+)
+
+// Values lists the valid configuration formats.
+func (ConfigFormat) Values() []string {
+	return []string{string(JSON), string(YAML), string(TOML)}
+}
+
+// ConfigFormatOf returns the format of a configuration file from its extension,
+// falling back to JSON for unknown ones.
+func ConfigFormatOf(file string) ConfigFormat {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	default:
+		return JSON
+	}
+}
+
+// ParseConfigFormat returns the format with the given name, e.g. "yaml".
+func ParseConfigFormat(name string) (ConfigFormat, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	if name == "yml" {
+		name = string(YAML)
+	}
+	if !slices.Contains(ConfigFormat("").Values(), name) {
+		return "", fmt.Errorf("%w %q, expected one of %s", ErrFireflyUnknownConfigFormat, name, strings.Join(ConfigFormat("").Values(), ", "))
+	}
+
+	return ConfigFormat(name), nil
+}
+
+// ConvertConfig translates a valid configuration from a format to another.
+// Comments and, for TOML, the order of the keys aren't preserved.
+func ConvertConfig(data []byte, from ConfigFormat, to ConfigFormat) ([]byte, error) {
+	if _, err := DecodeConfig(data, from); err != nil {
+		return nil, err
+	}
+	root, err := parseConfigNode(data, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	switch to {
+	case YAML:
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err = enc.Encode(toYAML(root)); err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+	case TOML:
+		var value any
+		value, err = toTOML(root, "")
+		if err != nil {
+			return nil, err
+		}
+		enc := toml.NewEncoder(&b)
+		enc.Indent = ""
+		err = enc.Encode(value)
+	default:
+		var data []byte
+		data, err = root.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Indent(&b, data, "", "  "); err != nil {
+			return nil, err
+		}
+		b.WriteByte('\n')
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// yamlErrorLine matches the line number in the errors returned by the YAML parser.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseYAMLNode parses a YAML document keeping track of the position of each value.
+func parseYAMLNode(data []byte) (*configNode, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	err := dec.Decode(&doc)
+	if errors.Is(err, io.EOF) {
+		return &configNode{kind: nullNode, pos: nodePosition{line: 1, column: 1}}, nil
+	}
+	if err != nil {
+		return nil, yamlError(err)
+	}
+	var next yaml.Node
+	if err = dec.Decode(&next); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return nil, yamlError(err)
+		}
+		return nil, ConfigErrors{{Message: "expected a single YAML document", Line: next.Line, Column: next.Column}}
+	}
+
+	return fromYAML(&doc)
+}
+
+// yamlError converts an error of the YAML parser into ConfigErrors, extracting its line.
+func yamlError(err error) error {
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return ConfigErrors{{Message: err.Error()}}
+	}
+	line, _ := strconv.Atoi(match[1])
+
+	return ConfigErrors{{Message: match[2], Line: line}}
+}
+
+// fromYAML converts a YAML node, resolving aliases and merge keys.
+func fromYAML(n *yaml.Node) (*configNode, error) {
+	node := &configNode{pos: nodePosition{line: n.Line, column: n.Column}}
+	node.keyPos = node.pos
+	invalid := func(format string, args ...any) error {
+		return ConfigErrors{{Message: fmt.Sprintf(format, args...), Line: n.Line, Column: n.Column}}
+	}
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			node.kind = nullNode
+			return node, nil
+		}
+		return fromYAML(n.Content[0])
+	case yaml.AliasNode:
+		alias, err := fromYAML(n.Alias)
+		if err != nil {
+			return nil, err
+		}
+		aliased := *alias
+		aliased.pos = node.pos
+		aliased.keyPos = node.pos
+		return &aliased, nil
+	case yaml.MappingNode:
+		node.kind = objectNode
+		node.fields = make(map[string]*configNode)
+		var merged []*configNode
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, ConfigErrors{{Message: "expected a scalar key", Line: key.Line, Column: key.Column}}
+			}
+			child, err := fromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			if key.ShortTag() == "!!merge" {
+				merged = append(merged, child)
+				continue
+			}
+			child.keyPos = nodePosition{line: key.Line, column: key.Column}
+			node.set(key.Value, child)
+		}
+		// Merged mappings only provide the keys that aren't set explicitly, the first ones taking precedence
+		for _, m := range merged {
+			sources := []*configNode{m}
+			if m.kind == arrayNode {
+				sources = m.items
+			}
+			for _, source := range sources {
+				if source.kind != objectNode {
+					return nil, invalid("merge key expects a mapping, got %s", source.kind)
+				}
+				for _, key := range source.keys {
+					if _, ok := node.fields[key]; !ok {
+						node.set(key, source.fields[key])
+					}
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		node.kind = arrayNode
+		for _, item := range n.Content {
+			child, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, child)
+		}
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			node.kind = nullNode
+		case "!!bool":
+			var b bool
+			if err := n.Decode(&b); err != nil {
+				return nil, invalid("%s", err)
+			}
+			node.kind = boolNode
+			node.value = b
+		case "!!int":
+			var i int64
+			if err := n.Decode(&i); err != nil {
+				return nil, invalid("invalid integer %q", n.Value)
+			}
+			node.kind = numberNode
+			node.value = json.Number(strconv.FormatInt(i, 10))
+		case "!!float":
+			var f float64
+			if err := n.Decode(&f); err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				return nil, invalid("invalid number %q", n.Value)
+			}
+			node.kind = numberNode
+			node.value = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+		default:
+			node.kind = stringNode
+			node.value = n.Value
+		}
+	}
+
+	return node, nil
+}
+
+// toYAML converts a node into a YAML one, writing multi-line strings as literal blocks.
+func toYAML(n *configNode) *yaml.Node {
+	switch n.kind {
+	case objectNode:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range n.keys {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, toYAML(n.fields[key]))
+		}
+		return node
+	case arrayNode:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range n.items {
+			node.Content = append(node.Content, toYAML(item))
+		}
+		return node
+	case stringNode:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: n.value.(string)}
+		if strings.Contains(node.Value, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node
+	case numberNode:
+		tag := "!!float"
+		if _, err := n.value.(json.Number).Int64(); err == nil {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: n.value.(json.Number).String()}
+	case boolNode:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(n.value.(bool))}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
+
+// parseTOMLNode parses a TOML document. Objects keep the order their keys were defined in,
+// but the parser doesn't report the position of the values.
+func parseTOMLNode(data []byte) (*configNode, error) {
+	var doc map[string]any
+	md, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, ConfigErrors{{Message: parseErr.Message, Line: parseErr.Position.Line, Column: parseErr.Position.Col}}
+		}
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	order := make(map[string]int)
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+
+	return fromTOML(doc, nil, order)
+}
+
+// fromTOML converts a decoded TOML value found at the given key.
+func fromTOML(value any, key toml.Key, order map[string]int) (*configNode, error) {
+	node := &configNode{}
+	switch v := value.(type) {
+	case map[string]any:
+		node.kind = objectNode
+		node.fields = make(map[string]*configNode)
+		keys := sortedKeys(v)
+		slices.SortStableFunc(keys, func(a, b string) int {
+			return order[append(slices.Clone(key), a).String()] - order[append(slices.Clone(key), b).String()]
+		})
+		for _, k := range keys {
+			child, err := fromTOML(v[k], append(slices.Clone(key), k), order)
+			if err != nil {
+				return nil, err
+			}
+			node.set(k, child)
+		}
+	case []map[string]any:
+		node.kind = arrayNode
+		for _, item := range v {
+			child, err := fromTOML(item, key, order)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, child)
+		}
+	case []any:
+		node.kind = arrayNode
+		for _, item := range v {
+			child, err := fromTOML(item, key, order)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, child)
+		}
+	case string:
+		node.kind = stringNode
+		node.value = v
+	case bool:
+		node.kind = boolNode
+		node.value = v
+	case int64:
+		node.kind = numberNode
+		node.value = json.Number(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, ConfigErrors{{Path: key.String(), Message: fmt.Sprintf("invalid number %v", v)}}
+		}
+		node.kind = numberNode
+		node.value = json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		node.kind = stringNode
+		node.value = v.Format(time.RFC3339Nano)
+	default:
+		return nil, ConfigErrors{{Path: key.String(), Message: fmt.Sprintf("unsupported value %v", v)}}
+	}
+
+	return node, nil
+}
+
+// toTOML converts a node into the values written by the TOML encoder, which has no null:
+// null fields are left out.
+func toTOML(n *configNode, path string) (any, error) {
+	switch n.kind {
+	case objectNode:
+		m := make(map[string]any, len(n.keys))
+		for _, key := range n.keys {
+			if n.fields[key].kind == nullNode {
+				continue
+			}
+			value, err := toTOML(n.fields[key], joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case arrayNode:
+		// Arrays of objects are written as arrays of tables
+		if len(n.items) > 0 && !slices.ContainsFunc(n.items, func(item *configNode) bool { return item.kind != objectNode }) {
+			tables := make([]map[string]any, 0, len(n.items))
+			for i, item := range n.items {
+				value, err := toTOML(item, fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return nil, err
+				}
+				tables = append(tables, value.(map[string]any))
+			}
+			return tables, nil
+		}
+		items := make([]any, 0, len(n.items))
+		for i, item := range n.items {
+			value, err := toTOML(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case numberNode:
+		if i, err := n.value.(json.Number).Int64(); err == nil {
+			return i, nil
+		}
+		return n.value.(json.Number).Float64()
+	case nullNode:
+		return nil, ConfigErrors{{Path: path, Message: "null values can't be written as TOML"}}
+	default:
+		return n.value, nil
+	}
+}
@@ -0,0 +1,120 @@
+package firefly
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		format   ConfigFormat
+		expected ConfigErrors
+	}{
+		{
+			name: "yaml with comments and merge keys",
+			config: `# Alerts on the checking account
+balance_alert:
+  - &alert
+    trigger: STORE_TRANSACTION
+    response: ACCOUNTS
+    secret: first
+    account_id: "1"
+    low_threshold: 100
+  - <<: *alert
+    secret: second
+    account_id: "2"
+`,
+			format: YAML,
+		},
+		{
+			name: "yaml errors",
+			config: `balance_alert:
+  - trigger: STORE_TRANSACTION
+    response: ACCOUNT
+    secret: first
+    account_id: 1
+`,
+			format: YAML,
+			expected: ConfigErrors{
+				{Path: "balance_alert[0].response", Message: `invalid value "ACCOUNT", expected one of TRANSACTIONS, ACCOUNTS, NONE`, Line: 3, Column: 15},
+				{Path: "balance_alert[0].account_id", Message: "expected string, got number", Line: 5, Column: 17},
+			},
+		},
+		{
+			name:     "yaml syntax error",
+			config:   "balance_alert:\n  - [\n",
+			format:   YAML,
+			expected: ConfigErrors{{Message: "did not find expected node content", Line: 2}},
+		},
+		{
+			name: "toml",
+			config: `# Alerts on the checking account
+[[balance_alert]]
+trigger = "STORE_TRANSACTION"
+response = "ACCOUNTS"
+secret = "first"
+account_id = "1"
+low_threshold = 100
+`,
+			format: TOML,
+		},
+		{
+			name: "toml errors",
+			config: `[[balance_alert]]
+trigger = "STORE_TRANSACTION"
+response = "ACCOUNTS"
+secret = "first"
+account_id = 1
+`,
+			format: TOML,
+			expected: ConfigErrors{
+				{Path: "balance_alert[0].account_id", Message: "expected string, got number"},
+			},
+		},
+		{
+			name:     "toml syntax error",
+			config:   "[[balance_alert]]\nsecret = \n",
+			format:   TOML,
+			expected: ConfigErrors{{Message: `expected value but found '\n' instead`, Line: 2, Column: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeConfig([]byte(tt.config), tt.format)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestConvertConfig(t *testing.T) {
+	data, err := os.ReadFile("../../config.json")
+	require.NoError(t, err)
+	expected, err := ParseConfig(data)
+	require.NoError(t, err)
+
+	for _, format := range []ConfigFormat{YAML, TOML} {
+		t.Run(string(format), func(t *testing.T) {
+			converted, err := ConvertConfig(data, JSON, format)
+			require.NoError(t, err)
+			config, err := DecodeConfig(converted, format)
+			require.NoError(t, err)
+			assert.Equal(t, expected, config)
+
+			back, err := ConvertConfig(converted, format, JSON)
+			require.NoError(t, err)
+			config, err = ParseConfig(back)
+			require.NoError(t, err)
+			assert.Equal(t, expected, config)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package firefly
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// limits caps the requests a client sends to Firefly III, so that bursts of webhooks, e.g. while importing or
+// backfilling transactions, don't flood its workers. Requests over the limits wait for their turn.
+type limits struct {
+	// requestsPerSecond is the rate of the token bucket requests are taken from, 0 doesn't limit the rate.
+	requestsPerSecond float64
+	// burst is the number of requests that can be sent at once after a quiet period.
+	burst int
+	// maxConcurrent is the number of requests in flight at most, 0 doesn't limit them.
+	maxConcurrent int
+	// slowWait is how long a request can wait for its turn before the wait is logged.
+	slowWait time.Duration
+}
+
+const defaultSlowWait = time.Second
+
+// WithRateLimit is a configuration function that limits the requests sent to requestsPerSecond, allowing bursts
+// of burst requests after a quiet period.
+func WithRateLimit(requestsPerSecond float64, burst int) FireflyOption {
+	return func(c *fireflyOpts) error {
+		if requestsPerSecond <= 0 || burst < 1 {
+			return errors.New("rate limit and burst must be positive")
+		}
+		c.limits.requestsPerSecond = requestsPerSecond
+		c.limits.burst = burst
+		return nil
+	}
+}
+
+// WithMaxConcurrent is a configuration function that limits the number of requests in flight at the same time.
+func WithMaxConcurrent(maxConcurrent int) FireflyOption {
+	return func(c *fireflyOpts) error {
+		if maxConcurrent < 1 {
+			return errors.New("max concurrent requests must be positive")
+		}
+		c.limits.maxConcurrent = maxConcurrent
+		return nil
+	}
+}
+
+// WithSlowWait is a configuration function that updates how long a request can wait for the rate limit and the
+// concurrent requests limit before the wait is logged.
+func WithSlowWait(slowWait time.Duration) FireflyOption {
+	return func(c *fireflyOpts) error {
+		c.limits.slowWait = slowWait
+		return nil
+	}
+}
+
+// limiter enforces the limits of a client.
+type limiter struct {
+	limits limits
+	logger *slog.Logger
+	// slots holds a value for each request in flight
+	slots chan struct{}
+	// tokens left in the bucket when it was last updated, negative when requests are waiting for them
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newLimiter(limits limits, logger *slog.Logger) *limiter {
+	if limits.slowWait == 0 {
+		limits.slowWait = defaultSlowWait
+	}
+	l := &limiter{limits: limits, logger: logger, tokens: float64(limits.burst), last: time.Now()}
+	if limits.maxConcurrent > 0 {
+		l.slots = make(chan struct{}, limits.maxConcurrent)
+	}
+
+	return l
+}
+
+// reserve takes a token from the bucket, returning how long to wait before it's available.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(float64(l.limits.burst), l.tokens+now.Sub(l.last).Seconds()*l.limits.requestsPerSecond)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.limits.requestsPerSecond * float64(time.Second))
+}
+
+// cancel puts back a token taken by a request that won't be sent.
+func (l *limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(float64(l.limits.burst), l.tokens+1)
+}
+
+// acquire waits until the request can be sent, returning the function to call once it completes.
+func (l *limiter) acquire(ctx context.Context, method string, path string) (func(), error) {
+	start := time.Now()
+	if l.limits.requestsPerSecond > 0 {
+		if err := sleep(ctx, l.reserve(start)); err != nil {
+			l.cancel()
+			return nil, err
+		}
+	}
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			release = func() { <-l.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if waited := time.Since(start); waited >= l.limits.slowWait {
+		l.logger.Warn(
+			"Firefly III request waited for the request limits",
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Duration("waited", waited),
+		)
+	}
+
+	return release, nil
+}
@@ -0,0 +1,102 @@
+package firefly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	client := NewFirefly(
+		server.URL,
+		WithApiKey("key"),
+		WithRateLimit(20, 2),
+		WithSlowWait(80*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	start := time.Now()
+	for range 6 {
+		_, err := client.GetBudget("1")
+		require.NoError(t, err)
+	}
+	// The burst goes through at once, the other 4 requests are 50ms apart
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+	assert.Zero(t, bytes.Count(logs.Bytes(), []byte("waited for the request limits")))
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			_, err := client.GetBudget("1")
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	assert.Positive(t, bytes.Count(logs.Bytes(), []byte("waited for the request limits")), "waits over the threshold are logged")
+}
+
+func TestRateLimitCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	defer server.Close()
+
+	client := NewFirefly(server.URL, WithApiKey("key"), WithRateLimit(1, 1))
+	_, err := client.GetBudget("1")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.GetBudgetContext(ctx, "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"data": {}}`)
+	}))
+	defer server.Close()
+
+	client := NewFirefly(server.URL, WithApiKey("key"), WithMaxConcurrent(2))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			_, err := client.GetBudget("1")
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestInvalidLimits(t *testing.T) {
+	var options fireflyOpts
+	assert.Error(t, WithRateLimit(0, 1)(&options))
+	assert.Error(t, WithRateLimit(1, 0)(&options))
+	assert.Error(t, WithMaxConcurrent(0)(&options))
+}
@@ -0,0 +1,208 @@
+package firefly
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RefKind is the kind of Firefly resource a configuration field references. Fields referencing a resource are
+// tagged with its kind, e.g. `ref:"account"`, and accept the name of the resource in place of its id.
+type RefKind string
+
+const (
+	AccountRef  RefKind = "account"
+	CategoryRef RefKind = "category"
+	BudgetRef   RefKind = "budget"
+	CurrencyRef RefKind = "currency"
+	LinkTypeRef RefKind = "link_type"
+)
+
+// namePrefix forces a value to be read as a name, for names made only of digits.
+const namePrefix = "name:"
+
+// Lookup indexes the ids of the resources of a kind by name.
+type Lookup struct {
+	kind RefKind
+	ids  map[string][]string
+}
+
+func newLookup(kind RefKind) *Lookup {
+	return &Lookup{kind: kind, ids: make(map[string][]string)}
+}
+
+func (l *Lookup) add(name string, id string) {
+	l.ids[name] = append(l.ids[name], id)
+}
+
+// ID returns the id of the resource with the given name, failing when none or more than one have it.
+func (l *Lookup) ID(name string) (string, error) {
+	ids := l.ids[name]
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("%w: %s %q", ErrFireflyNameNotFound, l.kind, name)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s %q matches ids %s, use one of them", ErrFireflyAmbiguousName, l.kind, name, strings.Join(ids, ", "))
+	}
+}
+
+// HasID checks if a resource has the given id.
+func (l *Lookup) HasID(id string) bool {
+	for _, ids := range l.ids {
+		if slices.Contains(ids, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NameResolver resolves the names used in configurations into ids.
+type NameResolver interface {
+	ResolveName(kind RefKind, name string) (string, error)
+}
+
+// IDVerifier checks the ids used in configurations exist. A NameResolver implementing it verifies the ids
+// when loading with WithIDVerification.
+type IDVerifier interface {
+	VerifyID(kind RefKind, id string) error
+}
+
+// fireflyResolver resolves names listing the resources of each kind once.
+type fireflyResolver struct {
+	ctx     context.Context
+	client  *Firefly
+	lookups map[RefKind]*Lookup
+	errs    map[RefKind]error
+}
+
+// NewNameResolver creates a NameResolver looking up names in the given Firefly instance.
+// Resources are listed the first time a name of their kind is resolved, so a new resolver should be used
+// each time the configuration is loaded.
+func NewNameResolver(client *Firefly) NameResolver {
+	return NewNameResolverContext(context.Background(), client)
+}
+
+// NewNameResolverContext is like NewNameResolver, cancelling the requests listing the resources with the context.
+func NewNameResolverContext(ctx context.Context, client *Firefly) NameResolver {
+	return &fireflyResolver{ctx: ctx, client: client, lookups: make(map[RefKind]*Lookup), errs: make(map[RefKind]error)}
+}
+
+func (r *fireflyResolver) ResolveName(kind RefKind, name string) (string, error) {
+	lookup, err := r.lookup(kind)
+	if err != nil {
+		return "", err
+	}
+
+	return lookup.ID(name)
+}
+
+func (r *fireflyResolver) VerifyID(kind RefKind, id string) error {
+	lookup, err := r.lookup(kind)
+	if err != nil {
+		return err
+	}
+	if !lookup.HasID(id) {
+		return fmt.Errorf("%w: %s %q", ErrFireflyIDNotFound, kind, id)
+	}
+
+	return nil
+}
+
+// lookup returns the lookup of the kind, listing its resources the first time.
+func (r *fireflyResolver) lookup(kind RefKind) (*Lookup, error) {
+	if err, ok := r.errs[kind]; ok {
+		return nil, err
+	}
+	lookup, ok := r.lookups[kind]
+	if !ok {
+		var err error
+		switch kind {
+		case AccountRef:
+			lookup, err = r.client.AccountLookupContext(r.ctx)
+		case CategoryRef:
+			lookup, err = r.client.CategoryLookupContext(r.ctx)
+		case BudgetRef:
+			lookup, err = r.client.BudgetLookupContext(r.ctx)
+		case CurrencyRef:
+			lookup, err = r.client.CurrencyLookupContext(r.ctx)
+		case LinkTypeRef:
+			lookup, err = r.client.LinkTypeLookupContext(r.ctx)
+		default:
+			err = fmt.Errorf("unknown resource kind %q", kind)
+		}
+		if err != nil {
+			// Failures are reported once for each name, without listing the resources again
+			r.errs[kind] = fmt.Errorf("listing %s names: %w", kind, err)
+			return nil, r.errs[kind]
+		}
+		r.lookups[kind] = lookup
+	}
+
+	return lookup, nil
+}
+
+// LoadOption configures how a configuration is loaded.
+type LoadOption func(v *validator)
+
+// WithNameResolver resolves the names used in place of ids. Without it names are kept as they are.
+func WithNameResolver(resolver NameResolver) LoadOption {
+	return func(v *validator) {
+		v.resolver = resolver
+	}
+}
+
+// WithIDVerification checks the ids used in place of names exist, when the NameResolver is an IDVerifier like
+// the ones of NewNameResolver. Without it ids are kept as they are.
+func WithIDVerification() LoadOption {
+	return func(v *validator) {
+		v.verifyIDs = true
+	}
+}
+
+// refName returns the name a reference holds, if it isn't an id.
+func refName(value string) (string, bool) {
+	if name, ok := strings.CutPrefix(value, namePrefix); ok {
+		return name, true
+	}
+	if _, err := strconv.ParseUint(value, 10, 64); err == nil || value == "" {
+		return "", false
+	}
+
+	return value, true
+}
+
+// resolveRefs replaces the names found in the node, a string or a list or map of strings, with their ids.
+func (v *validator) resolveRefs(node *configNode, kind RefKind, path string) {
+	switch node.kind {
+	case objectNode:
+		for _, key := range node.keys {
+			v.resolveRefs(node.fields[key], kind, fmt.Sprintf("%s.%s", path, key))
+		}
+	case arrayNode:
+		for i, item := range node.items {
+			v.resolveRefs(item, kind, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case stringNode:
+		value := node.value.(string)
+		name, ok := refName(value)
+		if !ok {
+			if verifier, isVerifier := v.resolver.(IDVerifier); v.verifyIDs && isVerifier && value != "" {
+				if err := verifier.VerifyID(kind, value); err != nil {
+					v.addError(node.pos, path, "%s", err)
+				}
+			}
+			return
+		}
+		id, err := v.resolver.ResolveName(kind, name)
+		if err != nil {
+			v.addError(node.pos, path, "%s", err)
+			return
+		}
+		node.value = id
+	}
+}
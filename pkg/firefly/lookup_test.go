@@ -0,0 +1,114 @@
+package firefly
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapResolver resolves names from a fixed list of lookups.
+type mapResolver map[RefKind]*Lookup
+
+func (r mapResolver) ResolveName(kind RefKind, name string) (string, error) {
+	return r[kind].ID(name)
+}
+
+func TestResolveNames(t *testing.T) {
+	accounts := newLookup(AccountRef)
+	accounts.add("Checking", "1")
+	accounts.add("Satispay", "4")
+	accounts.add("Cash", "5")
+	accounts.add("Cash", "9")
+	accounts.add("2024", "12")
+	categories := newLookup(CategoryRef)
+	categories.add("Groceries", "3")
+	resolver := mapResolver{AccountRef: accounts, CategoryRef: categories}
+
+	config, err := DecodeConfig([]byte(`{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "Satispay", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "def", "account_id": "7", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "ghi", "account_id": "name:2024", "low_threshold": 10}
+  ],
+  "notes_commands": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "jkl", "accounts": {"bank": "Checking"},
+     "when": {"category_ids": ["Groceries"]}}
+  ]
+}`), JSON, WithNameResolver(resolver))
+	require.NoError(t, err)
+
+	assert.Equal(t, "4", (*config)[BalanceAlert][0].(BalanceAlertConfig).AccountId)
+	assert.Equal(t, "7", (*config)[BalanceAlert][1].(BalanceAlertConfig).AccountId)
+	assert.Equal(t, "12", (*config)[BalanceAlert][2].(BalanceAlertConfig).AccountId)
+	notes := (*config)[NotesCommands][0].(NotesCommandsConfig)
+	assert.Equal(t, map[string]string{"bank": "1"}, notes.Accounts)
+	assert.Equal(t, []string{"3"}, notes.When.CategoryIds)
+
+	_, err = DecodeConfig([]byte(`{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "Savings", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "def", "account_id": "Cash", "low_threshold": 10}
+  ]
+}`), JSON, WithNameResolver(resolver))
+	assert.Equal(t, ConfigErrors{
+		{Path: "balance_alert[0].account_id", Message: `name not found: account "Savings"`, Line: 3, Column: 93},
+		{Path: "balance_alert[1].account_id", Message: `ambiguous name: account "Cash" matches ids 5, 9, use one of them`, Line: 4, Column: 93},
+	}, err)
+}
+
+func TestAccountLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/accounts", r.URL.Path)
+		page := r.URL.Query().Get("page")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": [{"type": "accounts", "id": "%s", "attributes": {"name": "Account %s"}}],
+			"meta": {"pagination": {"current_page": %s, "total_pages": 2}}}`, page, page, page)
+	}))
+	defer server.Close()
+
+	lookup, err := NewFirefly(server.URL, WithApiKey("key")).AccountLookup()
+	require.NoError(t, err)
+
+	id, err := lookup.ID("Account 2")
+	require.NoError(t, err)
+	assert.Equal(t, "2", id)
+	_, err = lookup.ID("Account 3")
+	assert.ErrorIs(t, err, ErrFireflyNameNotFound)
+}
+
+func TestVerifyIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/accounts", r.URL.Path)
+		fmt.Fprint(w, `{"data": [{"type": "accounts", "id": "1", "attributes": {"name": "Checking"}},
+			{"type": "accounts", "id": "4", "attributes": {"name": "Satispay"}}],
+			"meta": {"pagination": {"total_pages": 1}}}`)
+	}))
+	defer server.Close()
+	client := NewFirefly(server.URL, WithApiKey("key"))
+	data := []byte(`{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "4", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "def", "account_id": "7", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "ghi", "account_id": "Checking", "low_threshold": 10}
+  ]
+}`)
+
+	config, err := DecodeConfig(data, JSON, WithNameResolver(NewNameResolver(client)))
+	require.NoError(t, err)
+	assert.Equal(t, "7", (*config)[BalanceAlert][1].(BalanceAlertConfig).AccountId)
+
+	_, err = DecodeConfig(data, JSON, WithNameResolver(NewNameResolver(client)), WithIDVerification())
+	assert.Equal(t, ConfigErrors{
+		{Path: "balance_alert[1].account_id", Message: `id not found: account "7"`, Line: 4, Column: 93},
+	}, err)
+
+	// Resolvers that can't verify ids keep them as they are
+	_, err = DecodeConfig([]byte(`{"balance_alert": [
+  {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "7", "low_threshold": 10}
+]}`), JSON, WithNameResolver(mapResolver{AccountRef: newLookup(AccountRef)}), WithIDVerification())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+package firefly
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultsKey is the top level key holding the options inherited by every configuration value setting none.
+const defaultsKey = "defaults"
+
+// configExtensions lists the extensions of the files read from a configuration directory.
+var configExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// ConfigFiles returns the configuration files found at the given path, in the order they are merged.
+// The path can be a file, a directory, whose JSON, YAML and TOML files are read, or a glob pattern such as
+// "conf.d/*.yaml". Files are sorted by name, and hidden files are skipped.
+func ConfigFiles(path string) ([]string, error) {
+	var files []string
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration pattern %q: %w", path, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{path}, nil
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			file := filepath.Join(path, entry.Name())
+			if !IsConfigFile(file) {
+				continue
+			}
+			// Files can be links, like the ones of Kubernetes config maps
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no configuration file found in %s", path)
+	}
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// IsConfigFile checks if the file would be read from a configuration directory.
+func IsConfigFile(file string) bool {
+	name := filepath.Base(file)
+	return !strings.HasPrefix(name, ".") && slices.Contains(configExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// mergeNodes merges the configuration documents into one, appending the values of each action in order.
+// Defaults set by more than one document must match.
+func (v *validator) mergeNodes(roots []*configNode) *configNode {
+	if len(roots) == 1 {
+		return roots[0]
+	}
+
+	merged := &configNode{kind: objectNode, fields: make(map[string]*configNode)}
+	for _, root := range roots {
+		if root.kind != objectNode {
+			v.addError(root.pos, "", "expected object, got %s", root.kind)
+			continue
+		}
+		for _, key := range root.keys {
+			node := root.fields[key]
+			existing, ok := merged.fields[key]
+			switch {
+			case !ok:
+				merged.set(key, node)
+			case key == defaultsKey && existing.kind == objectNode && node.kind == objectNode:
+				v.mergeDefaults(existing, node)
+			case existing.kind == arrayNode && node.kind == arrayNode:
+				existing.items = append(existing.items, node.items...)
+			default:
+				v.addError(node.keyPos, key, "can't be merged with the %s value set in %s", existing.kind, existing.keyPos)
+			}
+		}
+	}
+
+	return merged
+}
+
+// mergeDefaults adds the defaults of a document to the ones of the previous documents.
+func (v *validator) mergeDefaults(defaults *configNode, node *configNode) {
+	for _, key := range node.keys {
+		value := node.fields[key]
+		existing, ok := defaults.fields[key]
+		if !ok {
+			defaults.set(key, value)
+			continue
+		}
+		a, errA := existing.MarshalJSON()
+		b, errB := value.MarshalJSON()
+		if errA != nil || errB != nil || !bytes.Equal(a, b) {
+			v.addError(value.keyPos, joinPath(defaultsKey, key), "conflicts with the value set in %s", existing.keyPos)
+		}
+	}
+}
+
+// applyDefaults sets the defaults on every configuration value having the option and not setting it,
+// removing them from the configuration.
+func (v *validator) applyDefaults(root *configNode) {
+	defaults, ok := root.fields[defaultsKey]
+	if !ok || root.kind != objectNode {
+		return
+	}
+	delete(root.fields, defaultsKey)
+	root.keys = slices.DeleteFunc(root.keys, func(key string) bool { return key == defaultsKey })
+	if defaults.kind != objectNode {
+		v.addError(defaults.pos, defaultsKey, "expected object, got %s", defaults.kind)
+		return
+	}
+
+	for _, key := range defaults.keys {
+		used := false
+		for _, t := range sortedKeys(configValueTypes) {
+			if _, ok := jsonFields(configValueTypes[t])[key]; !ok {
+				continue
+			}
+			used = true
+			node, ok := root.fields[string(t)]
+			if !ok || node.kind != arrayNode {
+				continue
+			}
+			for _, item := range node.items {
+				if _, ok := item.fields[key]; item.kind == objectNode && !ok {
+					item.set(key, defaults.fields[key])
+				}
+			}
+		}
+		if !used {
+			v.addError(defaults.fields[key].keyPos, joinPath(defaultsKey, key), "unknown field %q, no action has it", key)
+		}
+	}
+}
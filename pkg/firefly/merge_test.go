@@ -0,0 +1,174 @@
+package firefly
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"20-alerts.yaml":   "{}",
+		"10-cashback.json": "{}",
+		"30-fees.toml":     "",
+		".hidden.json":     "{}",
+		"README.md":        "",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.json"), 0o755))
+
+	files, err := ConfigFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "10-cashback.json"),
+		filepath.Join(dir, "20-alerts.yaml"),
+		filepath.Join(dir, "30-fees.toml"),
+	}, files)
+
+	files, err = ConfigFiles(filepath.Join(dir, "*.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "20-alerts.yaml")}, files)
+
+	files, err = ConfigFiles(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "README.md")}, files)
+
+	_, err = ConfigFiles(filepath.Join(dir, "*.yml"))
+	assert.EqualError(t, err, "no configuration file found in "+filepath.Join(dir, "*.yml"))
+}
+
+func TestLoadConfigDirectory(t *testing.T) {
+	lowThreshold := 10.0
+	alert := func(secret string, accountId string) BalanceAlertConfig {
+		return BalanceAlertConfig{
+			BaseConfig:   BaseConfig{Trigger: STORE_TRANSACTION, Response: RESPONSE_ACCOUNTS, Secret: Secret(secret)},
+			AccountId:    accountId,
+			LowThreshold: &lowThreshold,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected Config
+		errors   func(dir string) ConfigErrors
+	}{
+		{
+			name: "values appended in file order",
+			files: map[string]string{
+				"b.yaml": "balance_alert:\n  - trigger: STORE_TRANSACTION\n    response: ACCOUNTS\n    secret: second\n    account_id: \"2\"\n    low_threshold: 10\n",
+				"a.json": `{"balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "first", "account_id": "1", "low_threshold": 10}]}`,
+			},
+			expected: Config{BalanceAlert: {alert("first", "1"), alert("second", "2")}},
+		},
+		{
+			name: "defaults inherited by values not setting them",
+			files: map[string]string{
+				"00-defaults.yaml": "defaults:\n  low_threshold: 10\n  response: ACCOUNTS\n",
+				"10-alerts.json": `{"balance_alert": [
+  {"trigger": "STORE_TRANSACTION", "secret": "first", "account_id": "1"},
+  {"trigger": "STORE_TRANSACTION", "secret": "second", "account_id": "2", "low_threshold": 20}
+]}`,
+			},
+			expected: Config{BalanceAlert: {
+				alert("first", "1"),
+				func() BalanceAlertConfig {
+					value := alert("second", "2")
+					lowThreshold := 20.0
+					value.LowThreshold = &lowThreshold
+					return value
+				}(),
+			}},
+		},
+		{
+			name: "conflicting defaults",
+			files: map[string]string{
+				"a.json": `{"defaults": {"low_threshold": 10}}`,
+				"b.json": "{\n  \"defaults\": {\n    \"low_threshold\": 20\n  }\n}",
+			},
+			errors: func(dir string) ConfigErrors {
+				return ConfigErrors{{
+					File:    filepath.Join(dir, "b.json"),
+					Path:    "defaults.low_threshold",
+					Message: "conflicts with the value set in " + filepath.Join(dir, "a.json") + ":1:15",
+					Line:    3,
+					Column:  5,
+				}}
+			},
+		},
+		{
+			name: "values that can't be merged",
+			files: map[string]string{
+				"a.json": `{"balance_alert": []}`,
+				"b.json": `{"balance_alert": {}}`,
+			},
+			errors: func(dir string) ConfigErrors {
+				return ConfigErrors{{
+					File:    filepath.Join(dir, "b.json"),
+					Path:    "balance_alert",
+					Message: "can't be merged with the array value set in " + filepath.Join(dir, "a.json") + ":1:2",
+					Line:    1,
+					Column:  2,
+				}}
+			},
+		},
+		{
+			name: "unknown defaults",
+			files: map[string]string{
+				"a.json": `{"defaults": {"threshold": 10}}`,
+			},
+			errors: func(dir string) ConfigErrors {
+				return ConfigErrors{{
+					File:    filepath.Join(dir, "a.json"),
+					Path:    "defaults.threshold",
+					Message: `unknown field "threshold", no action has it`,
+					Line:    1,
+					Column:  15,
+				}}
+			},
+		},
+		{
+			name: "errors reported with their file",
+			files: map[string]string{
+				"a.json": `{}`,
+				"b.yaml": "balance_alert:\n  - trigger: STORE_TRANSACTION\n    response: ACCOUNTS\n    secret: first\n",
+			},
+			errors: func(dir string) ConfigErrors {
+				return ConfigErrors{{
+					File:    filepath.Join(dir, "b.yaml"),
+					Path:    "balance_alert[0]",
+					Message: `missing required field "account_id"`,
+					Line:    2,
+					Column:  5,
+				}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			config, err := LoadConfig(dir)
+			if tt.errors != nil {
+				var configErrors ConfigErrors
+				require.ErrorAs(t, err, &configErrors)
+				assert.Equal(t, tt.errors(dir), configErrors)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *config)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Account struct {
+	CreatedAt             *time.Time `json:"created_at"`
+	UpdatedAt             *time.Time `json:"updated_at"`
+	CurrentBalanceDate    *time.Time `json:"current_balance_date"`
+	OpeningBalanceDate    *string    `json:"opening_balance_date"`
+	AccountNumber         *string    `json:"account_number"`
+	AccountRole           *string    `json:"account_role"`
+	Bic                   *string    `json:"bic"`
+	CreditCardType        *string    `json:"credit_card_type"`
+	CurrentDebt           *string    `json:"current_debt"`
+	Iban                  *string    `json:"iban"`
+	Interest              *string    `json:"interest"`
+	InterestPeriod        *string    `json:"interest_period"`
+	LiabilityDirection    *string    `json:"liability_direction"`
+	LiabilityType         *string    `json:"liability_type"`
+	MonthlyPaymentDate    *string    `json:"monthly_payment_date"`
+	Notes                 *string    `json:"notes"`
+	Order                 *int       `json:"order"`
+	Latitude              *float64   `json:"latitude"`
+	Longitude             *float64   `json:"longitude"`
+	ZoomLevel             *int       `json:"zoom_level"`
+	ID                    string     `json:"id"`
+	Name                  string     `json:"name"`
+	Type                  string     `json:"type"`
+	CurrencyID            string     `json:"currency_id"`
+	CurrencyCode          string     `json:"currency_code"`
+	CurrencySymbol        string     `json:"currency_symbol"`
+	CurrentBalance        string     `json:"current_balance"`
+	OpeningBalance        string     `json:"opening_balance"`
+	VirtualBalance        string     `json:"virtual_balance"`
+	CurrencyDecimalPlaces int        `json:"currency_decimal_places"`
+	Active                bool       `json:"active"`
+	IncludeNetWorth       bool       `json:"include_net_worth"`
+}
+
+type AccountData struct {
+	Type       string  `json:"type"`
+	ID         string  `json:"id"`
+	Attributes Account `json:"attributes"`
+}
+
+type AccountResponse struct {
+	Data AccountData `json:"data"`
+}
+
+// Balance returns the current balance of the account.
+func (a Account) Balance() (float64, error) {
+	balance, err := strconv.ParseFloat(strings.TrimSpace(a.CurrentBalance), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account balance %q", a.CurrentBalance)
+	}
+
+	return balance, nil
+}
+
+// IsLiability checks if the account is a liability, e.g. a loan, a debt or a mortgage.
+func (a Account) IsLiability() bool {
+	return a.LiabilityType != nil && *a.LiabilityType != ""
+}
+
+// Debt returns the amount still owed of a liability, 0 for other accounts.
+func (a Account) Debt() (float64, error) {
+	if a.CurrentDebt == nil || strings.TrimSpace(*a.CurrentDebt) == "" {
+		return 0, nil
+	}
+	debt, err := strconv.ParseFloat(strings.TrimSpace(*a.CurrentDebt), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account debt %q", *a.CurrentDebt)
+	}
+
+	return debt, nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Budget struct {
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	Notes     *string    `json:"notes"`
+	Name      string     `json:"name"`
+	Order     int        `json:"order"`
+	Active    bool       `json:"active"`
+}
+
+type BudgetResponse struct {
+	Data struct {
+		Type       string `json:"type"`
+		ID         string `json:"id"`
+		Attributes Budget `json:"attributes"`
+	} `json:"data"`
+}
+
+type BudgetLimit struct {
+	Start                 time.Time `json:"start"`
+	End                   time.Time `json:"end"`
+	Period                *string   `json:"period"`
+	Spent                 *string   `json:"spent"`
+	BudgetID              string    `json:"budget_id"`
+	CurrencyID            string    `json:"currency_id"`
+	CurrencyCode          string    `json:"currency_code"`
+	CurrencySymbol        string    `json:"currency_symbol"`
+	Amount                string    `json:"amount"`
+	CurrencyDecimalPlaces int       `json:"currency_decimal_places"`
+}
+
+type BudgetLimitData struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	Attributes BudgetLimit `json:"attributes"`
+}
+
+type BudgetLimitListResponse struct {
+	Data []BudgetLimitData `json:"data"`
+	Meta Meta              `json:"meta"`
+}
+
+type BudgetData struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Attributes Budget `json:"attributes"`
+}
+
+type BudgetLimitResponse struct {
+	Data BudgetLimitData `json:"data"`
+}
+
+type StoreBudgetRequest struct {
+	Name   string  `json:"name"`
+	Notes  *string `json:"notes,omitempty"`
+	Active *bool   `json:"active,omitempty"`
+}
+
+type StoreBudgetLimitRequest struct {
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Amount     string `json:"amount"`
+	CurrencyID string `json:"currency_id,omitempty"`
+}
+
+// BudgetUsage is how much of a budget limit has been spent.
+type BudgetUsage struct {
+	Amount float64
+	Spent  float64
+	// Percentage is the part of the amount spent, 0 when the limit has no amount.
+	Percentage float64
+}
+
+// Usage returns how much of the limit has been spent, nothing when Firefly didn't compute it.
+func (l BudgetLimit) Usage() (BudgetUsage, error) {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(l.Amount), 64)
+	if err != nil {
+		return BudgetUsage{}, fmt.Errorf("invalid budget limit amount %q", l.Amount)
+	}
+	usage := BudgetUsage{Amount: amount}
+	if l.Spent == nil || strings.TrimSpace(*l.Spent) == "" {
+		return usage, nil
+	}
+	spent, err := strconv.ParseFloat(strings.TrimSpace(*l.Spent), 64)
+	if err != nil {
+		return BudgetUsage{}, fmt.Errorf("invalid budget limit spent %q", *l.Spent)
+	}
+	// Firefly reports the amount spent as a negative number
+	usage.Spent = math.Abs(spent)
+	if amount > 0 {
+		usage.Percentage = usage.Spent / amount * 100
+	}
+
+	return usage, nil
+}
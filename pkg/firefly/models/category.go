@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+type Category struct {
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	Notes     *string    `json:"notes"`
+	Name      string     `json:"name"`
+}
+
+type CategoryData struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Attributes Category `json:"attributes"`
+}
+
+type CategoryResponse struct {
+	Data CategoryData `json:"data"`
+}
+
+type StoreCategoryRequest struct {
+	Name  string  `json:"name"`
+	Notes *string `json:"notes,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+type Currency struct {
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+	Code          string     `json:"code"`
+	Name          string     `json:"name"`
+	Symbol        string     `json:"symbol"`
+	DecimalPlaces int        `json:"decimal_places"`
+	Enabled       bool       `json:"enabled"`
+	Default       bool       `json:"default"`
+}
+
+type CurrencyData struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Attributes Currency `json:"attributes"`
+}
+
+type CurrencyResponse struct {
+	Data CurrencyData `json:"data"`
+}
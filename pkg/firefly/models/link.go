@@ -1,8 +1,27 @@
 package models
 
+import (
+	"time"
+)
+
 type StoreLinkRequest struct {
 	LinkTypeID string  `json:"link_type_id"`
 	InwardID   string  `json:"inward_id"`
 	OutwardID  string  `json:"outward_id"`
 	Notes      *string `json:"notes"`
 }
+
+type LinkType struct {
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	Name      string     `json:"name"`
+	Inward    string     `json:"inward"`
+	Outward   string     `json:"outward"`
+	Editable  bool       `json:"editable"`
+}
+
+type LinkTypeData struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Attributes LinkType `json:"attributes"`
+}
@@ -0,0 +1,19 @@
+package models
+
+type Meta struct {
+	Pagination Pagination `json:"pagination"`
+}
+
+type Pagination struct {
+	Total       int `json:"total"`
+	Count       int `json:"count"`
+	PerPage     int `json:"per_page"`
+	CurrentPage int `json:"current_page"`
+	TotalPages  int `json:"total_pages"`
+}
+
+// ListResponse is a page of a list of resources.
+type ListResponse[T any] struct {
+	Data []T  `json:"data"`
+	Meta Meta `json:"meta"`
+}
@@ -0,0 +1,33 @@
+package models
+
+type PiggyBank struct {
+	TargetAmount          *string `json:"target_amount"`
+	LeftToSave            *string `json:"left_to_save"`
+	SavePerMonth          *string `json:"save_per_month"`
+	StartDate             *string `json:"start_date"`
+	TargetDate            *string `json:"target_date"`
+	Notes                 *string `json:"notes"`
+	Percentage            *int    `json:"percentage"`
+	Name                  string  `json:"name"`
+	AccountID             string  `json:"account_id"`
+	AccountName           string  `json:"account_name"`
+	CurrencyID            string  `json:"currency_id"`
+	CurrencyCode          string  `json:"currency_code"`
+	CurrencySymbol        string  `json:"currency_symbol"`
+	CurrentAmount         string  `json:"current_amount"`
+	CurrencyDecimalPlaces int     `json:"currency_decimal_places"`
+	Order                 int     `json:"order"`
+	Active                bool    `json:"active"`
+}
+
+type PiggyBankResponse struct {
+	Data struct {
+		Type       string    `json:"type"`
+		ID         string    `json:"id"`
+		Attributes PiggyBank `json:"attributes"`
+	} `json:"data"`
+}
+
+type UpdatePiggyBankRequest struct {
+	CurrentAmount string `json:"current_amount"`
+}
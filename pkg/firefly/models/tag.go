@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+type Tag struct {
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	Date        *string    `json:"date"`
+	Description *string    `json:"description"`
+	Tag         string     `json:"tag"`
+}
+
+type TagData struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Attributes Tag    `json:"attributes"`
+}
+
+type TagResponse struct {
+	Data TagData `json:"data"`
+}
+
+type StoreTagRequest struct {
+	Tag         string  `json:"tag"`
+	Date        *string `json:"date,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
@@ -154,3 +154,22 @@ type UpsertTransactionResponse struct {
 		} `json:"attributes"`
 	} `json:"data"`
 }
+
+// TransactionGroup is a transaction as returned by the API, made of one or more splits.
+type TransactionGroup struct {
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	User         string                `json:"user"`
+	GroupTitle   *string               `json:"group_title"`
+	Transactions []TransactionResponse `json:"transactions"`
+}
+
+type TransactionGroupData struct {
+	Type       string           `json:"type"`
+	ID         string           `json:"id"`
+	Attributes TransactionGroup `json:"attributes"`
+}
+
+type TransactionGroupResponse struct {
+	Data TransactionGroupData `json:"data"`
+}
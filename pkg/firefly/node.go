@@ -0,0 +1,265 @@
+package firefly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// nodeKind is the type of a configuration value.
+type nodeKind string
+
+const (
+	objectNode nodeKind = "object"
+	arrayNode  nodeKind = "array"
+	stringNode nodeKind = "string"
+	numberNode nodeKind = "number"
+	boolNode   nodeKind = "boolean"
+	nullNode   nodeKind = "null"
+)
+
+// nodePosition is the 1-based line and column of a value in the source document, zero when unknown,
+// and the file of the document when it's read from one.
+type nodePosition struct {
+	file   string
+	line   int
+	column int
+}
+
+func (p nodePosition) String() string {
+	s := fmt.Sprintf("%d:%d", p.line, p.column)
+	if p.file != "" {
+		s = p.file + ":" + s
+	}
+
+	return s
+}
+
+// configNode is a configuration value with its position in the source document, whatever its format.
+// Numbers are kept as json.Number, so that every format is decoded as if it were JSON.
+type configNode struct {
+	fields map[string]*configNode
+	value  any
+	kind   nodeKind
+	keys   []string
+	items  []*configNode
+	pos    nodePosition
+	// keyPos is the position of the key when the value belongs to an object.
+	keyPos nodePosition
+}
+
+// parseConfigNode parses a configuration document in the given format.
+// Syntax errors are returned as ConfigErrors with their position.
+func parseConfigNode(data []byte, format ConfigFormat) (*configNode, error) {
+	switch format {
+	case YAML:
+		return parseYAMLNode(data)
+	case TOML:
+		return parseTOMLNode(data)
+	default:
+		return parseJSONNode(data)
+	}
+}
+
+// set adds a field to an object node, replacing the previous value of duplicate keys like encoding/json does.
+func (n *configNode) set(key string, child *configNode) {
+	if _, ok := n.fields[key]; !ok {
+		n.keys = append(n.keys, key)
+	}
+	n.fields[key] = child
+}
+
+// MarshalJSON encodes the node keeping the order of the object keys.
+func (n *configNode) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	switch n.kind {
+	case objectNode:
+		b.WriteByte('{')
+		for i, key := range n.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, err := marshalScalar(key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := n.fields[key].MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			b.Write(k)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+	case arrayNode:
+		b.WriteByte('[')
+		for i, item := range n.items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			v, err := item.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			b.Write(v)
+		}
+		b.WriteByte(']')
+	default:
+		return marshalScalar(n.value)
+	}
+
+	return b.Bytes(), nil
+}
+
+// marshalScalar encodes a scalar value without escaping HTML characters, common in titles like "Food & drinks".
+func marshalScalar(value any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// setFile sets the file the node and its children were read from.
+func (n *configNode) setFile(file string) {
+	n.pos.file = file
+	n.keyPos.file = file
+	for _, child := range n.fields {
+		child.setFile(file)
+	}
+	for _, item := range n.items {
+		item.setFile(file)
+	}
+}
+
+// lookup returns the node at the given path, e.g. "transfer[1].fixed_amount", or the deepest existing one.
+func (n *configNode) lookup(path string) *configNode {
+	node := n
+	for _, segment := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(segment, "[")
+		if key != "" {
+			child, ok := node.fields[key]
+			if !ok {
+				return node
+			}
+			node = child
+		}
+		for rest != "" {
+			var idx int
+			if _, err := fmt.Sscanf(rest, "%d]", &idx); err != nil || idx >= len(node.items) {
+				return node
+			}
+			node = node.items[idx]
+			_, rest, _ = strings.Cut(rest, "[")
+		}
+	}
+
+	return node
+}
+
+// parseJSONNode parses a JSON document keeping track of the position of each value.
+func parseJSONNode(data []byte) (*configNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := parseNode(dec, data)
+	if err == nil {
+		if _, err = dec.Token(); err == nil {
+			err = &json.SyntaxError{Offset: dec.InputOffset()}
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// The offset of syntax errors follows the invalid character
+			line, column := position(data, int(syntaxErr.Offset)-1)
+			return nil, ConfigErrors{{Message: syntaxErr.Error(), Line: line, Column: column}}
+		}
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	return root, nil
+}
+
+// parseNode parses the next value returned by the decoder.
+func parseNode(dec *json.Decoder, data []byte) (*configNode, error) {
+	node := &configNode{pos: jsonPosition(data, int(dec.InputOffset()))}
+	node.keyPos = node.pos
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := tok.(type) {
+	case json.Delim:
+		if value == '{' {
+			node.kind = objectNode
+			node.fields = make(map[string]*configNode)
+			for dec.More() {
+				keyPos := jsonPosition(data, int(dec.InputOffset()))
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := parseNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				child.keyPos = keyPos
+				node.set(keyTok.(string), child)
+			}
+		} else {
+			node.kind = arrayNode
+			for dec.More() {
+				child, err := parseNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, child)
+			}
+		}
+		// Closing delimiter
+		if _, err = dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.kind = stringNode
+		node.value = value
+	case json.Number:
+		node.kind = numberNode
+		node.value = value
+	case bool:
+		node.kind = boolNode
+		node.value = value
+	case nil:
+		node.kind = nullNode
+	}
+
+	return node, nil
+}
+
+// jsonPosition returns the position of the first byte from offset that isn't whitespace or a JSON separator.
+func jsonPosition(data []byte, offset int) nodePosition {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	line, column := position(data, offset)
+
+	return nodePosition{line: line, column: column}
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(data []byte, offset int) (line int, column int) {
+	offset = min(offset, len(data))
+	line = bytes.Count(data[:offset], []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(data[:offset], '\n')
+
+	return line, column
+}
@@ -0,0 +1,139 @@
+package firefly
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how the requests to Firefly III failing with a transient error are retried: a network
+// error or a 429, 502, 503 or 504 response, which Firefly returns e.g. while running its cron jobs.
+// Only idempotent requests are retried, plus the creation of transactions rejecting duplicates, which Firefly
+// refuses instead of creating them twice when the first attempt got through: the existing transaction is returned then.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent at most, 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for each following one.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, unless Firefly asks to wait longer with Retry-After.
+	MaxDelay time.Duration
+	// MaxRetryAfter is the longest wait Firefly can ask with Retry-After, the request failing instead when it asks
+	// to wait longer. MaxDelay when 0.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy of clients not configuring one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      10 * time.Second,
+	MaxRetryAfter: time.Minute,
+}
+
+// retryStatuses lists the response statuses of transient errors.
+var retryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// WithRetryPolicy is a configuration function that updates how failed requests are retried.
+func WithRetryPolicy(policy RetryPolicy) FireflyOption {
+	return func(c *fireflyOpts) error {
+		if policy.MaxAttempts < 1 || policy.BaseDelay < 0 || policy.MaxDelay < policy.BaseDelay || policy.MaxRetryAfter < 0 {
+			return errors.New("invalid retry policy")
+		}
+		c.retry = &policy
+		return nil
+	}
+}
+
+// WithLogger is a configuration function that updates the logger used to report retries.
+func WithLogger(logger *slog.Logger) FireflyOption {
+	return func(c *fireflyOpts) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// isIdempotent checks if sending a request with the method more than once has the same effect as sending it once.
+func isIdempotent(method string) bool {
+	return method != http.MethodPost && method != http.MethodPatch
+}
+
+// shouldRetry checks if the attempt failed with a transient error. err is the error sending the request, r the
+// response when there is one.
+func shouldRetry(ctx context.Context, r *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	return slices.Contains(retryStatuses, r.StatusCode)
+}
+
+// delay returns how long to wait before the given retry, starting from 1: an exponential backoff with full jitter,
+// so that many clients failing together don't retry together, or the time Firefly asks to wait if longer.
+// It returns false when Firefly asks to wait longer than MaxRetryAfter, so that the request isn't retried.
+func (p RetryPolicy) delay(retry int, r *http.Response) (time.Duration, bool) {
+	backoff := p.MaxDelay
+	if shift := retry - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		backoff = p.BaseDelay << shift
+	}
+	delay := time.Duration(0)
+	if backoff > 0 {
+		delay = rand.N(backoff + 1)
+	}
+	if r != nil {
+		if after, ok := retryAfter(r.Header.Get("Retry-After"), time.Now()); ok && after > delay {
+			maxRetryAfter := p.MaxRetryAfter
+			if maxRetryAfter == 0 {
+				maxRetryAfter = p.MaxDelay
+			}
+			if after > maxRetryAfter {
+				return 0, false
+			}
+			delay = after
+		}
+	}
+
+	return delay, true
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of seconds or an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the delay, returning early with the error of the context when it's done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
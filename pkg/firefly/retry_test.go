@@ -0,0 +1,282 @@
+package firefly
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		request  func(client *Firefly) error
+		attempts int
+		err      bool
+	}{
+		{
+			name:     "idempotent request retried until it succeeds",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			request: func(client *Firefly) error {
+				_, err := client.GetBudget("1")
+				return err
+			},
+			attempts: 3,
+		},
+		{
+			name:     "idempotent request failing every attempt",
+			statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			request: func(client *Firefly) error {
+				_, err := client.SetPiggyBankAmount("1", "10.00")
+				return err
+			},
+			attempts: 3,
+			err:      true,
+		},
+		{
+			name:     "errors that aren't transient",
+			statuses: []int{http.StatusUnprocessableEntity, http.StatusOK},
+			request: func(client *Firefly) error {
+				_, err := client.GetBudget("1")
+				return err
+			},
+			attempts: 1,
+			err:      true,
+		},
+		{
+			name:     "request that isn't idempotent",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			request: func(client *Firefly) error {
+				return client.LinkTransactions("1", "2", "3")
+			},
+			attempts: 1,
+			err:      true,
+		},
+		{
+			name:     "transaction rejecting duplicates",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			request: func(client *Firefly) error {
+				_, err := client.CreateTransaction(&models.StoreTransactionRequest{ErrorIfDuplicateHash: true})
+				return err
+			},
+			attempts: 2,
+		},
+		{
+			name:     "transaction allowing duplicates",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			request: func(client *Firefly) error {
+				_, err := client.CreateTransaction(&models.StoreTransactionRequest{})
+				return err
+			},
+			attempts: 1,
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				w.WriteHeader(tt.statuses[len(bodies)-1])
+				fmt.Fprint(w, `{"data": {}}`)
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			client := NewFirefly(
+				server.URL,
+				WithApiKey("key"),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+			err := tt.request(client)
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, bodies, tt.attempts)
+			for _, body := range bodies {
+				assert.Equal(t, bodies[0], body, "every attempt must send the same body")
+			}
+			assert.Equal(t, tt.attempts-1, bytes.Count(logs.Bytes(), []byte("Retrying Firefly III request")))
+		})
+	}
+}
+
+func TestCreateTransactionResponseLost(t *testing.T) {
+	duplicate := `{"message": "Duplicate of transaction #42.", "errors": {"transactions.0.description": ["Duplicate of transaction #42."]}}`
+
+	tests := []struct {
+		name     string
+		dropped  bool
+		requests []string
+		err      bool
+	}{
+		{
+			name:     "response of the first attempt lost",
+			dropped:  true,
+			requests: []string{"POST /api/v1/transactions", "POST /api/v1/transactions", "GET /api/v1/transactions/42"},
+		},
+		{
+			name:     "duplicate rejected by the first attempt",
+			requests: []string{"POST /api/v1/transactions"},
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.ReadAll(r.Body)
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				switch {
+				case r.Method == http.MethodGet:
+					fmt.Fprint(w, `{"data": {"type": "transactions", "id": "42"}}`)
+				case tt.dropped && len(requests) == 1:
+					// The transaction is created, but the connection is closed before the response is sent
+					conn, _, err := http.NewResponseController(w).Hijack()
+					require.NoError(t, err)
+					_ = conn.Close()
+				default:
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					fmt.Fprint(w, duplicate)
+				}
+			}))
+			defer server.Close()
+
+			client := NewFirefly(
+				server.URL,
+				WithApiKey("key"),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+			)
+			res, err := client.CreateTransaction(&models.StoreTransactionRequest{ErrorIfDuplicateHash: true})
+			assert.Equal(t, tt.requests, requests)
+			if tt.err {
+				assert.ErrorContains(t, err, "Duplicate of transaction #42.")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "42", res.Data.ID)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempts int
+		err      bool
+	}{
+		{
+			name: "wait shorter than the limit",
+			policy: RetryPolicy{
+				MaxAttempts:   2,
+				BaseDelay:     time.Millisecond,
+				MaxDelay:      time.Millisecond,
+				MaxRetryAfter: 2 * time.Second,
+			},
+			attempts: 2,
+		},
+		{
+			name: "wait longer than the limit",
+			policy: RetryPolicy{
+				MaxAttempts:   2,
+				BaseDelay:     time.Millisecond,
+				MaxDelay:      time.Millisecond,
+				MaxRetryAfter: 500 * time.Millisecond,
+			},
+			attempts: 1,
+			err:      true,
+		},
+		{
+			name:     "wait longer than MaxDelay without a limit",
+			policy:   RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			attempts: 1,
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			var waited time.Duration
+			var last time.Time
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts == 1 {
+					last = time.Now()
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				waited = time.Since(last)
+				fmt.Fprint(w, `{"data": {}}`)
+			}))
+			defer server.Close()
+
+			client := NewFirefly(server.URL, WithApiKey("key"), WithRetryPolicy(tt.policy))
+			start := time.Now()
+			_, err := client.GetBudget("1")
+			assert.Equal(t, tt.attempts, attempts)
+			if tt.err {
+				require.Error(t, err)
+				assert.Less(t, time.Since(start), time.Second)
+			} else {
+				require.NoError(t, err)
+				assert.GreaterOrEqual(t, waited, time.Second)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "120", expected: 2 * time.Minute, ok: true},
+		{value: "-1", expected: 0, ok: true},
+		{value: "Fri, 15 Mar 2024 10:30:30 GMT", expected: 30 * time.Second, ok: true},
+		{value: "Fri, 15 Mar 2024 10:29:00 GMT", expected: 0, ok: true},
+		{value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			delay, ok := retryAfter(tt.value, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, delay)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 8: time.Second, 100: time.Second} {
+		for range 20 {
+			delay, ok := policy.delay(retry, nil)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, limit)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package firefly
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a cron-like expression of the times a configuration is active, made of the minute, hour, day of
+// month, month and day of week fields, e.g. "* 8-18 * * 1-5" for working hours. Every field must match, and
+// each field is a list of values, ranges and steps such as "*", "1,15", "1-5" or "*/10". Sunday is 0 or 7.
+type Window string
+
+// windowFields lists the name and the range of the values of each field of a Window.
+var windowFields = []struct {
+	name   string
+	lo, hi int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func (w *Window) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if _, err := Window(s).parse(); err != nil {
+		return err
+	}
+	*w = Window(s)
+
+	return nil
+}
+
+// parse returns the set of values each field matches.
+func (w Window) parse() ([][]bool, error) {
+	fields := strings.Fields(string(w))
+	if len(fields) != len(windowFields) {
+		return nil, fmt.Errorf("invalid window %q, expected %d fields: minute, hour, day of month, month and day of week", w, len(windowFields))
+	}
+	sets := make([][]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseWindowField(field, windowFields[i].lo, windowFields[i].hi)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q in window %q: %w", windowFields[i].name, field, w, err)
+		}
+		sets[i] = set
+	}
+	// Sunday can be written as 7 as well
+	sets[4][0] = sets[4][0] || sets[4][7]
+
+	return sets, nil
+}
+
+// parseWindowField returns the values between lo and hi matched by the field.
+func parseWindowField(field string, lo int, hi int) ([]bool, error) {
+	set := make([]bool, hi+1)
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("step must be a positive number")
+			}
+		}
+		from, to := lo, hi
+		if rangePart != "*" {
+			start, end, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(start); err != nil {
+				return nil, fmt.Errorf("%q is not a number", start)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(end); err != nil {
+					return nil, fmt.Errorf("%q is not a number", end)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("values must be between %d and %d", lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// Matches checks if the time is inside the window.
+func (w Window) Matches(t time.Time) bool {
+	sets, err := w.parse()
+	if err != nil {
+		return false
+	}
+	values := []int{t.Minute(), t.Hour(), t.Day(), int(t.Month()), int(t.Weekday())}
+	for i, v := range values {
+		if !sets[i][v] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ActiveAt checks if the configuration is enabled and active at the given time, returning the reason why it
+// isn't otherwise. Dates are compared in the time zone of the given time.
+func (c BaseConfig) ActiveAt(t time.Time) (bool, string) {
+	if c.Enabled != nil && !*c.Enabled {
+		return false, "disabled"
+	}
+	date := Date(t.Format(dateLayout))
+	if c.ValidFrom != nil && date < *c.ValidFrom {
+		return false, fmt.Sprintf("valid from %s", *c.ValidFrom)
+	}
+	if c.ValidUntil != nil && date > *c.ValidUntil {
+		return false, fmt.Sprintf("valid until %s", *c.ValidUntil)
+	}
+	if len(c.Windows) > 0 {
+		for _, w := range c.Windows {
+			if w.Matches(t) {
+				return true, ""
+			}
+		}
+		return false, "outside of the activity windows"
+	}
+
+	return true, ""
+}
+
+// alwaysActive checks if the configuration is active whatever the time.
+func (c BaseConfig) alwaysActive() bool {
+	return (c.Enabled == nil || *c.Enabled) && c.ValidFrom == nil && c.ValidUntil == nil && len(c.Windows) == 0
+}
+
+// validateSchedule checks the configuration can be active at some time.
+func (c BaseConfig) validateSchedule() error {
+	if c.ValidFrom != nil && c.ValidUntil != nil && *c.ValidFrom > *c.ValidUntil {
+		return newFieldError("valid_until", "must not be before valid_from, the configuration would never apply")
+	}
+
+	return nil
+}
+
+// messageDate returns the time the configurations applying to the message are evaluated at: the date of the
+// transactions, so backdated ones are handled as when they happened, or the current time for other messages.
+func messageDate(msg WebhookMessage) time.Time {
+	if content, ok := msg.Content.(WebhookMessageTransaction); ok && len(content.Transactions) > 0 {
+		return content.Transactions[0].Date
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,149 @@
+package firefly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowMatches(t *testing.T) {
+	// Friday 15 March 2024, 10:30
+	friday := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	sunday := time.Date(2024, time.March, 17, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   Window
+		time     time.Time
+		expected bool
+	}{
+		{name: "every time", window: "* * * * *", time: friday, expected: true},
+		{name: "weekdays", window: "* * * * 1-5", time: friday, expected: true},
+		{name: "weekdays on sunday", window: "* * * * 1-5", time: sunday, expected: false},
+		{name: "sunday as 7", window: "* * * * 6,7", time: sunday, expected: true},
+		{name: "hours range", window: "* 8-18 * * *", time: friday, expected: true},
+		{name: "hours outside range", window: "* 11-18 * * *", time: friday, expected: false},
+		{name: "steps", window: "*/15 * * * *", time: friday, expected: true},
+		{name: "steps from value", window: "5/10 * * * *", time: friday, expected: false},
+		{name: "month and day", window: "* * 15 3 *", time: friday, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.window.Matches(tt.time))
+		})
+	}
+}
+
+func TestWindowInvalid(t *testing.T) {
+	tests := []struct {
+		window   string
+		expected string
+	}{
+		{window: `"* * *"`, expected: `invalid window "* * *", expected 5 fields: minute, hour, day of month, month and day of week`},
+		{window: `"60 * * * *"`, expected: `invalid minute "60" in window "60 * * * *": values must be between 0 and 59`},
+		{window: `"* * * * mon"`, expected: `invalid day of week "mon" in window "* * * * mon": "mon" is not a number`},
+		{window: `"*/0 * * * *"`, expected: `invalid minute "*/0" in window "*/0 * * * *": step must be a positive number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			var w Window
+			assert.EqualError(t, w.UnmarshalJSON([]byte(tt.window)), tt.expected)
+		})
+	}
+}
+
+func TestActiveAt(t *testing.T) {
+	date := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	disabled := false
+	from, until := Date("2024-03-16"), Date("2024-03-14")
+
+	tests := []struct {
+		name     string
+		config   BaseConfig
+		expected string
+	}{
+		{name: "always active", config: BaseConfig{}, expected: ""},
+		{name: "disabled", config: BaseConfig{Enabled: &disabled}, expected: "disabled"},
+		{name: "not valid yet", config: BaseConfig{ValidFrom: &from}, expected: "valid from 2024-03-16"},
+		{name: "expired", config: BaseConfig{ValidUntil: &until}, expected: "valid until 2024-03-14"},
+		{name: "inside a window", config: BaseConfig{Windows: []Window{"* * * * 0,6", "* * * * 5"}}, expected: ""},
+		{name: "outside the windows", config: BaseConfig{Windows: []Window{"* * * * 0,6"}}, expected: "outside of the activity windows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, reason := tt.config.ActiveAt(date)
+			assert.Equal(t, tt.expected == "", active)
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+func TestFindConfigsInactive(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+  "mirror": [
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+      "base_url": "https://one.example.com", "api_key": "key",
+      "valid_until": "2024-03-31", "stop": true
+    },
+    {
+      "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def",
+      "base_url": "https://two.example.com", "api_key": "key"
+    }
+  ]
+}`))
+	require.NoError(t, err)
+
+	// Backdated transactions are evaluated at their date
+	msg := WebhookMessage{
+		Trigger:  STORE_TRANSACTION,
+		Response: RESPONSE_TRANSACTIONS,
+		Content: WebhookMessageTransaction{Transactions: []models.Transaction{
+			{Date: time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC)},
+		}},
+	}
+	matches := config.FindConfigs(msg)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "mirror[0]", matches[0].String())
+	assert.Empty(t, matches[0].Inactive)
+
+	// An inactive value doesn't stop the evaluation
+	msg.Content = WebhookMessageTransaction{Transactions: []models.Transaction{
+		{Date: time.Date(2024, time.April, 2, 0, 0, 0, 0, time.UTC)},
+	}}
+	matches = config.FindConfigs(msg)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "valid until 2024-03-31", matches[0].Inactive)
+	assert.Empty(t, matches[1].Inactive)
+
+	found, err := config.FindConfig(Mirror, msg)
+	require.NoError(t, err)
+	assert.Equal(t, "https://two.example.com", found.(MirrorConfig).BaseUrl)
+}
+
+func TestConfigWithEnabled(t *testing.T) {
+	config := Config{
+		BalanceAlert: {BalanceAlertConfig{AccountId: "1"}, BalanceAlertConfig{AccountId: "2"}},
+		Cashback:     {CashbackConfig{}},
+	}
+
+	disabled, err := config.WithEnabled(BalanceAlert, 1, false)
+	require.NoError(t, err)
+	enabled := false
+	assert.Equal(t, Config{
+		BalanceAlert: {BalanceAlertConfig{AccountId: "1"}, BalanceAlertConfig{BaseConfig: BaseConfig{Enabled: &enabled}, AccountId: "2"}},
+		Cashback:     {CashbackConfig{}},
+	}, *disabled)
+	assert.Nil(t, config[BalanceAlert][1].Base().Enabled, "the original configuration must not change")
+
+	_, err = config.WithEnabled(BalanceAlert, 2, false)
+	assert.ErrorIs(t, err, ErrFireflyConfigNotFound)
+	_, err = config.WithEnabled(Transfer, 0, true)
+	assert.ErrorIs(t, err, ErrFireflyConfigNotFound)
+}
@@ -0,0 +1,133 @@
+package firefly
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in logs and encoded configurations.
+const redacted = "[redacted]"
+
+// Secret is a configuration value that must never be logged, like webhook secrets and api keys.
+// It's printed, logged and encoded as JSON redacted: use string(secret) to read its value.
+type Secret string
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// redactedSecrets returns the names of the secrets of the value still set to the redacted placeholder, e.g. when a
+// configuration returned by the admin API is uploaded again without setting them.
+func redactedSecrets(value ConfigValue) []string {
+	var names []string
+	v := reflect.ValueOf(value)
+	fields := jsonFields(v.Type())
+	for _, name := range sortedKeys(fields) {
+		if fields[name].Type == reflect.TypeFor[Secret]() && v.FieldByName(fields[name].Name).String() == redacted {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// secretFileSuffix is appended to the name of a secret field to read its value from a file,
+// e.g. secret_file for secret.
+const secretFileSuffix = "_file"
+
+// envVariable matches ${NAME} references to environment variables, while $$ escapes a dollar sign.
+var envVariable = regexp.MustCompile(`\$(\$|\{([A-Za-z_][A-Za-z0-9_]*)\})`)
+
+// interpolate replaces the references to environment variables in the value of a string node, which must be a
+// secret when it has any: secrets are redacted wherever they are printed, while other values are logged and returned
+// by the admin API. $$ is unescaped in every string.
+func (v *validator) interpolate(node *configNode, path string, secret bool) {
+	// Defaults are shared by every configuration value they apply to
+	if _, ok := v.interpolated[node]; ok {
+		return
+	}
+	if v.interpolated == nil {
+		v.interpolated = make(map[*configNode]struct{})
+	}
+	v.interpolated[node] = struct{}{}
+
+	reported := false
+	node.value = envVariable.ReplaceAllStringFunc(node.value.(string), func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		name := ref[2 : len(ref)-1]
+		if !secret {
+			if !reported {
+				v.addError(node.pos, path, "must not reference environment variables, only secrets can")
+				reported = true
+			}
+			return ref
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			v.addError(node.pos, path, "environment variable %s is not set", name)
+		}
+		return value
+	})
+}
+
+// readSecretFiles sets the secrets of each configuration value from the files given in their _file field,
+// e.g. secret_file, so that they can be mounted as Docker or Kubernetes secrets.
+func (c Config) readSecretFiles() error {
+	var errs ConfigErrors
+	for _, t := range sortedKeys(c) {
+		for i, value := range c[t] {
+			path := fmt.Sprintf("%s[%d]", t, i)
+			v := reflect.New(reflect.TypeOf(value)).Elem()
+			v.Set(reflect.ValueOf(value))
+			fields := jsonFields(v.Type())
+			for _, name := range sortedKeys(fields) {
+				if fields[name].Type != reflect.TypeFor[Secret]() {
+					continue
+				}
+				fileField, ok := fields[name+secretFileSuffix]
+				if !ok {
+					continue
+				}
+				file := v.FieldByName(fileField.Name).String()
+				if file == "" {
+					continue
+				}
+				secret := v.FieldByName(fields[name].Name)
+				if secret.String() != "" {
+					errs = append(errs, ConfigError{
+						Path:    joinPath(path, name+secretFileSuffix),
+						Message: fmt.Sprintf("must not be set together with %s", name),
+					})
+					continue
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					errs = append(errs, ConfigError{Path: joinPath(path, name+secretFileSuffix), Message: err.Error()})
+					continue
+				}
+				secret.SetString(strings.TrimSpace(string(data)))
+			}
+			c[t][i] = v.Interface().(ConfigValue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
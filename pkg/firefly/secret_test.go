@@ -0,0 +1,138 @@
+package firefly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("from-file\n"), 0o600))
+	t.Setenv("WEBHOOK_SECRET", "from-env")
+	t.Setenv("ALERT_ACCOUNT", "3")
+	lowThreshold := 10.0
+
+	tests := []struct {
+		name     string
+		value    string
+		expected BalanceAlertConfig
+		errors   ConfigErrors
+	}{
+		{
+			name:  "environment variables",
+			value: `"secret": "${WEBHOOK_SECRET}", "account_id": "3"`,
+			expected: BalanceAlertConfig{
+				BaseConfig:   BaseConfig{Trigger: STORE_TRANSACTION, Response: RESPONSE_ACCOUNTS, Secret: "from-env"},
+				AccountId:    "3",
+				LowThreshold: &lowThreshold,
+			},
+		},
+		{
+			name:  "escaped dollar",
+			value: `"secret": "$${WEBHOOK_SECRET}", "account_id": "$$1"`,
+			expected: BalanceAlertConfig{
+				BaseConfig:   BaseConfig{Trigger: STORE_TRANSACTION, Response: RESPONSE_ACCOUNTS, Secret: "${WEBHOOK_SECRET}"},
+				AccountId:    "$1",
+				LowThreshold: &lowThreshold,
+			},
+		},
+		{
+			name:  "environment variable outside secrets",
+			value: `"secret": "abc", "account_id": "${ALERT_ACCOUNT}"`,
+			errors: ConfigErrors{{
+				Path:    "balance_alert[0].account_id",
+				Message: "must not reference environment variables, only secrets can",
+				Line:    1,
+				Column:  108,
+			}},
+		},
+		{
+			name:   "unset environment variable",
+			value:  `"secret": "${MISSING_WEBHOOK_SECRET}", "account_id": "1"`,
+			errors: ConfigErrors{{Path: "balance_alert[0].secret", Message: "environment variable MISSING_WEBHOOK_SECRET is not set", Line: 1, Column: 87}},
+		},
+		{
+			name:  "secret file",
+			value: fmt.Sprintf(`"secret_file": %q, "account_id": "1"`, secretFile),
+			expected: BalanceAlertConfig{
+				BaseConfig:   BaseConfig{Trigger: STORE_TRANSACTION, Response: RESPONSE_ACCOUNTS, Secret: "from-file", SecretFile: secretFile},
+				AccountId:    "1",
+				LowThreshold: &lowThreshold,
+			},
+		},
+		{
+			name:   "missing secret file",
+			value:  fmt.Sprintf(`"secret_file": %q, "account_id": "1"`, filepath.Join(dir, "missing")),
+			errors: ConfigErrors{{Path: "balance_alert[0].secret_file", Line: 1, Column: 77}},
+		},
+		{
+			name:   "secret and secret file",
+			value:  fmt.Sprintf(`"secret": "abc", "secret_file": %q, "account_id": "1"`, secretFile),
+			errors: ConfigErrors{{Path: "balance_alert[0].secret_file", Message: "must not be set together with secret", Line: 1, Column: 94}},
+		},
+		{
+			name:   "no secret",
+			value:  `"account_id": "1"`,
+			errors: ConfigErrors{{Path: "balance_alert[0].secret", Message: "must be set, directly or with secret_file", Line: 1, Column: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"balance_alert": [{"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", ` + tt.value + `, "low_threshold": 10}]}`
+			config, err := ParseConfig([]byte(data))
+			if tt.errors != nil {
+				var configErrors ConfigErrors
+				require.ErrorAs(t, err, &configErrors)
+				if tt.errors[0].Message == "" {
+					// Read errors depend on the operating system
+					configErrors[0].Message = ""
+				}
+				assert.Equal(t, tt.errors, configErrors)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, (*config)[BalanceAlert][0])
+		})
+	}
+}
+
+func TestConfigSecretsDefaults(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "a$$b")
+	data := `{
+  "defaults": {"secret": "${WEBHOOK_SECRET}"},
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "account_id": "1", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "account_id": "2", "low_threshold": 10}
+  ]
+}`
+	config, err := ParseConfig([]byte(data))
+	require.NoError(t, err)
+	// The default is shared by both values, its variables are replaced only once
+	for _, value := range (*config)[BalanceAlert] {
+		assert.Equal(t, Secret("a$$b"), value.Base().Secret)
+	}
+}
+
+func TestSecretRedacted(t *testing.T) {
+	config := BalanceAlertConfig{BaseConfig: BaseConfig{Secret: "super-secret"}}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("config", "config", config, "secret", config.Secret)
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+
+	assert.NotContains(t, logs.String(), "super-secret")
+	assert.NotContains(t, string(data), "super-secret")
+	assert.NotContains(t, fmt.Sprint(config), "super-secret")
+	assert.Equal(t, "super-secret", string(config.Secret))
+}
@@ -0,0 +1,90 @@
+package firefly
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+)
+
+// TemplateData is what templates of the transactions created by an action can reference,
+// e.g. "Cashback {{.Source.DestinationName}} {{.Date | month}}".
+type TemplateData struct {
+	// Source is the transaction triggering the webhook.
+	Source models.Transaction
+	// Amount is the amount of the created transaction, formatted with the decimal places of its currency.
+	Amount string
+	// Config is the configuration value of the action, e.g. a TransferConfig.
+	Config ConfigValue
+	// Date is the date of the source transaction.
+	Date time.Time
+}
+
+// templateFuncs are the functions templates can use besides the text/template ones.
+var templateFuncs = template.FuncMap{
+	"month": func(t time.Time) string { return t.Format("January") },
+	"year":  func(t time.Time) int { return t.Year() },
+	"date":  func(layout string, t time.Time) string { return t.Format(layout) },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// Template is a text field of the transactions created by an action, written as a text/template.
+// Plain strings are templates too, rendered as they are. It's parsed when loaded, so an invalid one fails
+// loading the configuration, and again each time it's rendered, keeping configurations comparable.
+type Template string
+
+func (t *Template) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if _, err := Template(s).parse(); err != nil {
+		return err
+	}
+	*t = Template(s)
+
+	return nil
+}
+
+func (t Template) parse() (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).Parse(string(t))
+}
+
+// Render executes the template with the given data, returning fallback when the template isn't set.
+func (t *Template) Render(data TemplateData, fallback string) (string, error) {
+	if t == nil {
+		return fallback, nil
+	}
+	tmpl, err := t.parse()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// validate executes the template with empty data of the given configuration, reporting as errors of the
+// given field the references to fields that don't exist and the functions called with values of the wrong type.
+func (t *Template) validate(field string, config ConfigValue) error {
+	if t == nil {
+		return nil
+	}
+	tmpl, err := t.parse()
+	if err == nil {
+		err = tmpl.Execute(io.Discard, TemplateData{Config: config})
+	}
+	if err != nil {
+		return newFieldError(field, "%s", err)
+	}
+
+	return nil
+}
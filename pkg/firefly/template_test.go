@@ -0,0 +1,52 @@
+package firefly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akyrey/firefly-iii-webhooks/pkg/firefly/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRender(t *testing.T) {
+	date := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	data := TemplateData{
+		Source: models.Transaction{DestinationName: "Amazon", Description: "Books"},
+		Amount: "1.50",
+		Config: CashbackConfig{SourceMustHaveTag: "Cashback"},
+		Date:   date,
+	}
+
+	tmpl := func(text string) *Template {
+		t := Template(text)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		template *Template
+		expected string
+	}{
+		{name: "not set", template: nil, expected: "fallback"},
+		{name: "plain text", template: tmpl("Cashback"), expected: "Cashback"},
+		{
+			name:     "source and date",
+			template: tmpl("Cashback {{.Source.DestinationName}} {{.Date | month}}"),
+			expected: "Cashback Amazon March",
+		},
+		{
+			name:     "amount, config and functions",
+			template: tmpl(`{{.Amount}} {{.Config.SourceMustHaveTag | upper}} {{.Date | date "2006-01-02"}}`),
+			expected: "1.50 CASHBACK 2024-03-15",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := tt.template.Render(data, "fallback")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rendered)
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package firefly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ConfigError describes a problem found in a configuration file.
+type ConfigError struct {
+	File    string `json:"file,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+func (e ConfigError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%d:", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, "%d:", e.Column)
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+
+	return b.String()
+}
+
+// ConfigErrors lists every problem found in a configuration file.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// FieldError is a problem with the value of a configuration field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// newFieldError creates a FieldError with a formatted message.
+func newFieldError(field string, format string, args ...any) error {
+	return FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// enum is implemented by types accepting only a fixed list of values.
+type enum interface {
+	Values() []string
+}
+
+// ValidateConfig checks the JSON configuration, reporting every error found instead of stopping at the first one.
+func ValidateConfig(data []byte) error {
+	_, err := ParseConfig(data)
+	return err
+}
+
+// ParseConfig decodes the JSON configuration checking it against the schema of each action and,
+// when it's well-formed, validating its values. Errors are returned as ConfigErrors with their position.
+func ParseConfig(data []byte) (*Config, error) {
+	return DecodeConfig(data, JSON)
+}
+
+// DecodeConfig is like ParseConfig for a configuration written in the given format.
+func DecodeConfig(data []byte, format ConfigFormat, opts ...LoadOption) (*Config, error) {
+	root, err := parseConfigNode(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeConfigNodes([]*configNode{root}, opts...)
+}
+
+// decodeConfigNodes merges the parsed configuration documents, checks them and decodes them into a Config.
+func decodeConfigNodes(roots []*configNode, opts ...LoadOption) (*Config, error) {
+	v := validator{}
+	for _, opt := range opts {
+		opt(&v)
+	}
+	root := v.mergeNodes(roots)
+	v.applyDefaults(root)
+	v.validateRoot(root)
+	if len(v.errors) > 0 {
+		return nil, v.sorted()
+	}
+
+	// Every format is decoded through its JSON form, sharing the decoding of each action
+	data, err := json.Marshal(root)
+	if err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+	var config Config
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	err = config.readSecretFiles()
+	if err == nil {
+		err = config.Validate()
+	}
+	var configErrors ConfigErrors
+	if errors.As(err, &configErrors) {
+		for _, configErr := range configErrors {
+			node := root.lookup(configErr.Path)
+			v.addError(node.keyPos, configErr.Path, "%s", configErr.Message)
+		}
+		return nil, v.sorted()
+	}
+
+	return &config, nil
+}
+
+// Validate checks every configuration value, reporting contradictions, secrets shared by different webhooks
+// and values that can never run because a previous one always applies and stops the evaluation.
+func (c Config) Validate() error {
+	var errs ConfigErrors
+	// The first value using each secret, with its path
+	secrets := make(map[string]ConfigValue)
+	secretPaths := make(map[string]string)
+	for _, t := range sortedKeys(c) {
+		for i, value := range c[t] {
+			path := fmt.Sprintf("%s[%d]", t, i)
+			for _, err := range flattenErrors(errors.Join(value.Validate(), value.Base().validateWhen(), value.Base().validateSchedule())) {
+				var fieldErr FieldError
+				if errors.As(err, &fieldErr) {
+					errs = append(errs, ConfigError{Path: joinPath(path, fieldErr.Field), Message: fieldErr.Message})
+				} else {
+					errs = append(errs, ConfigError{Path: path, Message: err.Error()})
+				}
+			}
+
+			for _, name := range redactedSecrets(value) {
+				errs = append(errs, ConfigError{
+					Path:    joinPath(path, name),
+					Message: fmt.Sprintf("must be set to the actual value, %s is only returned in place of secrets", redacted),
+				})
+			}
+			secret := string(value.Base().Secret)
+			if strings.TrimSpace(secret) == "" {
+				errs = append(errs, ConfigError{Path: joinPath(path, "secret"), Message: "must be set, directly or with secret_file"})
+			} else if secret == redacted {
+				// Already reported with the other redacted secrets
+			} else if other, ok := secrets[secret]; !ok {
+				secrets[secret], secretPaths[secret] = value, path
+			} else if !value.Base().sameWebhook(other) {
+				// Values triggered by the same webhook share its secret, while other webhooks have their own
+				errs = append(errs, ConfigError{
+					Path: joinPath(path, "secret"),
+					Message: fmt.Sprintf(
+						"already used by %s with another trigger or response, each webhook should have its own secret",
+						secretPaths[secret],
+					),
+				})
+			}
+
+			for j, previous := range c[t] {
+				// Values with a condition or a schedule don't always apply
+				if j == i || previous.Base().When != nil || !previous.Base().alwaysActive() || !previous.Base().Stop {
+					continue
+				}
+				if runsBefore(previous, j, value, i) && previous.shadows(value) {
+					errs = append(errs, ConfigError{
+						Path:    path,
+						Message: fmt.Sprintf("unreachable, %s[%d] always applies first and stops", t, j),
+					})
+					break
+				}
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// runsBefore checks if the value at index i runs before the one at index j, when both apply.
+func runsBefore(value ConfigValue, i int, other ConfigValue, j int) bool {
+	priority, otherPriority := value.Base().Priority, other.Base().Priority
+
+	return priority > otherPriority || priority == otherPriority && i < j
+}
+
+// flattenErrors returns the errors joined in err, if any.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+
+	return []error{err}
+}
+
+// validator collects the errors found while walking the configuration.
+type validator struct {
+	resolver  NameResolver
+	verifyIDs bool
+	errors    ConfigErrors
+	// interpolated lists the string nodes whose environment variables are already replaced.
+	interpolated map[*configNode]struct{}
+}
+
+// sorted returns the errors collected ordered by position.
+func (v *validator) sorted() ConfigErrors {
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].File != v.errors[j].File {
+			return v.errors[i].File < v.errors[j].File
+		}
+		if v.errors[i].Line != v.errors[j].Line {
+			return v.errors[i].Line < v.errors[j].Line
+		}
+		return v.errors[i].Column < v.errors[j].Column
+	})
+
+	return v.errors
+}
+
+// addError records an error at the position of the given node.
+func (v *validator) addError(pos nodePosition, path string, format string, args ...any) {
+	v.errors = append(v.errors, ConfigError{
+		File:    pos.file,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Line:    pos.line,
+		Column:  pos.column,
+	})
+}
+
+// validateRoot checks the top level object, mapping each action to the list of its configurations.
+func (v *validator) validateRoot(root *configNode) {
+	if root.kind != objectNode {
+		v.addError(root.pos, "", "expected object, got %s", root.kind)
+		return
+	}
+
+	for _, key := range root.keys {
+		node := root.fields[key]
+		valueType, ok := configValueTypes[ConfigType(key)]
+		if !ok {
+			v.addError(node.keyPos, key, "unknown action %q, expected one of %s", key, strings.Join(configTypeNames(), ", "))
+			continue
+		}
+		if node.kind != arrayNode {
+			v.addError(node.pos, key, "expected array, got %s", node.kind)
+			continue
+		}
+		for i, item := range node.items {
+			v.validateValue(item, valueType, fmt.Sprintf("%s[%d]", key, i))
+		}
+	}
+}
+
+// validateValue checks the node against the given Go type, recursing into structs, slices and maps.
+func (v *validator) validateValue(node *configNode, t reflect.Type, path string) {
+	if t.Kind() == reflect.Pointer {
+		if node.kind == nullNode {
+			return
+		}
+		t = t.Elem()
+	}
+
+	// Types decoding themselves are checked decoding them
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		data, err := json.Marshal(node)
+		if err == nil {
+			err = json.Unmarshal(data, reflect.New(t).Interface())
+		}
+		if err != nil {
+			v.addError(node.pos, path, "%s", err)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		v.validateStruct(node, t, path)
+	case reflect.Slice, reflect.Array:
+		if node.kind != arrayNode {
+			v.addError(node.pos, path, "expected array, got %s", node.kind)
+			return
+		}
+		for i, item := range node.items {
+			v.validateValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if node.kind != objectNode {
+			v.addError(node.pos, path, "expected object, got %s", node.kind)
+			return
+		}
+		for _, key := range node.keys {
+			v.validateValue(node.fields[key], t.Elem(), fmt.Sprintf("%s.%s", path, key))
+		}
+	case reflect.String:
+		if node.kind != stringNode {
+			v.addError(node.pos, path, "expected string, got %s", node.kind)
+			return
+		}
+		v.interpolate(node, path, t == reflect.TypeFor[Secret]())
+		if e, ok := reflect.Zero(t).Interface().(enum); ok && !slices.Contains(e.Values(), node.value.(string)) {
+			v.addError(node.pos, path, "invalid value %q, expected one of %s", node.value, strings.Join(e.Values(), ", "))
+		}
+	case reflect.Bool:
+		if node.kind != boolNode {
+			v.addError(node.pos, path, "expected boolean, got %s", node.kind)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if node.kind != numberNode {
+			v.addError(node.pos, path, "expected integer, got %s", node.kind)
+			return
+		}
+		if _, err := node.value.(json.Number).Int64(); err != nil {
+			v.addError(node.pos, path, "expected integer, got %s", node.value)
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.kind != numberNode {
+			v.addError(node.pos, path, "expected number, got %s", node.kind)
+		}
+	default:
+		// Any value is accepted by interfaces
+	}
+}
+
+// validateStruct checks the fields of an object against the JSON fields of a struct,
+// reporting unknown and missing required fields. Fields without omitempty are required.
+func (v *validator) validateStruct(node *configNode, t reflect.Type, path string) {
+	if node.kind != objectNode {
+		v.addError(node.pos, path, "expected object, got %s", node.kind)
+		return
+	}
+
+	fields := jsonFields(t)
+	for _, key := range node.keys {
+		field, ok := fields[key]
+		if !ok {
+			v.addError(node.fields[key].keyPos, joinPath(path, key), "unknown field %q", key)
+			continue
+		}
+		v.validateValue(node.fields[key], field.Type, joinPath(path, key))
+		if kind := field.Tag.Get("ref"); kind != "" && v.resolver != nil {
+			v.resolveRefs(node.fields[key], RefKind(kind), joinPath(path, key))
+		}
+	}
+
+	for _, name := range sortedKeys(fields) {
+		if _, ok := node.fields[name]; !ok && isRequired(fields[name]) {
+			v.addError(node.pos, path, "missing required field %q", name)
+		}
+	}
+}
+
+// jsonFields returns the fields of a struct indexed by their JSON name, including the ones of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embeddedName, embedded := range jsonFields(field.Type) {
+				if _, ok := fields[embeddedName]; !ok {
+					fields[embeddedName] = embedded
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+
+	return fields
+}
+
+// isRequired checks if a struct field must be present in the configuration.
+func isRequired(field reflect.StructField) bool {
+	_, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return !slices.Contains(strings.Split(options, ","), "omitempty")
+}
+
+// configTypeNames returns the sorted names of the available actions.
+func configTypeNames() []string {
+	names := make([]string, 0, len(configValueTypes))
+	for t := range configValueTypes {
+		names = append(names, string(t))
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// sortedKeys returns the keys of a map in alphabetical order.
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// joinPath appends a key to a JSON path.
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s.%s", path, key)
+}
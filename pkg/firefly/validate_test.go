@@ -0,0 +1,319 @@
+package firefly
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected ConfigErrors
+	}{
+		{
+			name:     "empty configuration",
+			config:   `{}`,
+			expected: nil,
+		},
+		{
+			name:   "syntax error",
+			config: "{\n  \"transfer\": [,]\n}",
+			expected: ConfigErrors{
+				{Message: "invalid character ',' looking for beginning of value", Line: 2, Column: 16},
+			},
+		},
+		{
+			name:   "not an object",
+			config: `[]`,
+			expected: ConfigErrors{
+				{Message: "expected object, got array", Line: 1, Column: 1},
+			},
+		},
+		{
+			name:   "unknown action",
+			config: "{\n  \"cashbak\": []\n}",
+			expected: ConfigErrors{
+				{
+					Path:    "cashbak",
+					Message: `unknown action "cashbak", expected one of balance_alert, budget_warning, cashback, foreign_fee, mirror, notes_commands, piggy_bank, split_ticket, transfer`,
+					Line:    2,
+					Column:  3,
+				},
+			},
+		},
+		{
+			name: "every error of a configuration",
+			config: `{
+  "budget_warning": [
+    {
+      "trigger": "STORE_TRANSACTIONS",
+      "response": "TRANSACTIONS",
+      "thresholds": [75, "90"],
+      "budget_id": "1"
+    }
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "budget_warning[0].trigger",
+					Message: `invalid value "STORE_TRANSACTIONS", expected one of STORE_TRANSACTION, UPDATE_TRANSACTION, DESTROY_TRANSACTION`,
+					Line:    4,
+					Column:  18,
+				},
+				{Path: "budget_warning[0].thresholds[1]", Message: "expected number, got string", Line: 6, Column: 26},
+				{Path: "budget_warning[0].budget_id", Message: `unknown field "budget_id"`, Line: 7, Column: 7},
+			},
+		},
+		{
+			name: "wrong types",
+			config: `{"piggy_bank": [{
+  "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "deposit",
+  "piggy_bank_id": 3, "source_account_id": "4", "remove": "yes", "percentage": null
+}]}`,
+			expected: ConfigErrors{
+				{Path: "piggy_bank[0].piggy_bank_id", Message: "expected string, got number", Line: 3, Column: 20},
+				{Path: "piggy_bank[0].remove", Message: "expected boolean, got string", Line: 3, Column: 59},
+			},
+		},
+		{
+			name: "contradicting values",
+			config: `{
+  "split_ticket": [{
+    "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "deposit",
+    "source_account_id": "1", "destination_account_id": "4", "destination_currency_id": "1",
+    "destination_currency_decimal_places": 2, "split_amount": 0, "link_type_id": "1"
+  }],
+  "transfer": [{
+    "trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def", "type": "deposit",
+    "source_account_id": "4", "source_must_have_tag": "Cashback", "destination_account_id": "8",
+    "destination_currency_id": "1", "destination_currency_decimal_places": 2, "title": "Transfer",
+    "category_id": "3", "fixed_amount": 0.02, "modulo_amount": 1, "link_type_id": "1"
+  }]
+}`,
+			expected: ConfigErrors{
+				{Path: "split_ticket[0].type", Message: "must be withdrawal, the configuration would never apply", Line: 3, Column: 82},
+				{Path: "split_ticket[0].split_amount", Message: "must be greater than 0", Line: 5, Column: 47},
+				{Path: "transfer[0].modulo_amount", Message: "must not be set together with fixed_amount", Line: 11, Column: 47},
+			},
+		},
+		{
+			name: "values shadowed by a previous one with stop",
+			config: `{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "high_threshold": 99, "stop": true},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 5},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 1, "priority": 1}
+  ],
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def"}
+  ]
+}`,
+			expected: ConfigErrors{
+				{Path: "balance_alert[2]", Message: "unreachable, balance_alert[1] always applies first and stops", Line: 5, Column: 5},
+			},
+		},
+		{
+			name: "redacted secrets uploaded again",
+			config: `{
+  "mirror": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "[redacted]",
+     "base_url": "http://mirror", "api_key": "[redacted]"}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "mirror[0].secret",
+					Message: "must be set to the actual value, [redacted] is only returned in place of secrets",
+					Line:    3,
+					Column:  66,
+				},
+				{
+					Path:    "mirror[0].api_key",
+					Message: "must be set to the actual value, [redacted] is only returned in place of secrets",
+					Line:    4,
+					Column:  35,
+				},
+			},
+		},
+		{
+			name: "secret shared by the same action with another trigger",
+			config: `{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10},
+    {"trigger": "UPDATE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10},
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "2", "low_threshold": 10}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "balance_alert[1].secret",
+					Message: "already used by balance_alert[0] with another trigger or response, each webhook should have its own secret",
+					Line:    4,
+					Column:  63,
+				},
+			},
+		},
+		{
+			name: "secrets shared by different webhooks",
+			config: `{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10}
+  ],
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc"},
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def"}
+  ],
+  "piggy_bank": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def", "type": "withdrawal",
+     "piggy_bank_id": "1", "source_account_id": "2", "modulo_amount": 1},
+    {"trigger": "UPDATE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def", "type": "withdrawal",
+     "piggy_bank_id": "1", "source_account_id": "2", "modulo_amount": 1}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "budget_warning[0].secret",
+					Message: "already used by balance_alert[0] with another trigger or response, each webhook should have its own secret",
+					Line:    6,
+					Column:  66,
+				},
+				{
+					Path:    "piggy_bank[1].secret",
+					Message: "already used by budget_warning[1] with another trigger or response, each webhook should have its own secret",
+					Line:    12,
+					Column:  67,
+				},
+			},
+		},
+		{
+			name: "invalid when conditions",
+			config: `{
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+     "when": {"description": "(", "weekdays": ["sabato"]}}
+  ]
+}`,
+			expected: ConfigErrors{
+				{Path: "budget_warning[0].when.description", Message: "error parsing regexp: missing closing ): `(`", Line: 4, Column: 30},
+				{
+					Path:    "budget_warning[0].when.weekdays[0]",
+					Message: `invalid value "sabato", expected one of sunday, monday, tuesday, wednesday, thursday, friday, saturday`,
+					Line:    4,
+					Column:  48,
+				},
+			},
+		},
+		{
+			name: "when conditions never matching",
+			config: `{
+  "balance_alert": [
+    {"trigger": "STORE_TRANSACTION", "response": "ACCOUNTS", "secret": "abc", "account_id": "1", "low_threshold": 10,
+     "when": {"amount": {"min": 1}}}
+  ],
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "def",
+     "when": {"amount": {"min": 10, "max": 1}, "any": [{"tags": {"all": ["A"], "none": ["A"]}}]}},
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "ghi"}
+  ]
+}`,
+			expected: ConfigErrors{
+				{Path: "balance_alert[0].when", Message: "requires the TRANSACTIONS response, the configuration would never apply", Line: 4, Column: 6},
+				{Path: "budget_warning[0].when.amount", Message: "min must not be greater than max", Line: 8, Column: 15},
+				{Path: "budget_warning[0].when.any[0].tags", Message: `tag "A" is both required and excluded`, Line: 8, Column: 57},
+			},
+		},
+		{
+			name: "invalid schedules",
+			config: `{
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "windows": ["* * * 13 *"]}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "budget_warning[0].windows[0]",
+					Message: `invalid month "13" in window "* * * 13 *": values must be between 1 and 12`,
+					Line:    3,
+					Column:  95,
+				},
+			},
+		},
+		{
+			name: "schedules never active",
+			config: `{
+  "budget_warning": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc",
+     "valid_from": "2024-03-01", "valid_until": "2024-02-01"}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "budget_warning[0].valid_until",
+					Message: "must not be before valid_from, the configuration would never apply",
+					Line:    4,
+					Column:  34,
+				},
+			},
+		},
+		{
+			name: "invalid templates",
+			config: `{
+  "foreign_fee": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+     "title": "Fee {{.Source.Amount", "link_type_id": "1", "source_account_id": "1", "destination_account_id": "2",
+     "category_id": "3", "percentage": 1}
+  ]
+}`,
+			expected: ConfigErrors{
+				{Path: "foreign_fee[0].title", Message: "template: :1: unclosed action", Line: 4, Column: 15},
+			},
+		},
+		{
+			name: "templates referencing missing fields",
+			config: `{
+  "split_ticket": [
+    {"trigger": "STORE_TRANSACTION", "response": "TRANSACTIONS", "secret": "abc", "type": "withdrawal",
+     "link_type_id": "1", "source_account_id": "1", "destination_account_id": "2", "split_amount": 8,
+     "title": "{{.Source.Nope}}", "notes": "{{.Date | upper}}"}
+  ]
+}`,
+			expected: ConfigErrors{
+				{
+					Path:    "split_ticket[0].title",
+					Message: `template: :1:9: executing "" at <.Source.Nope>: can't evaluate field Nope in type models.Transaction`,
+					Line:    5,
+					Column:  6,
+				},
+				{
+					Path:    "split_ticket[0].notes",
+					Message: `template: :1:10: executing "" at <upper>: wrong type for value; expected string; got time.Time`,
+					Line:    5,
+					Column:  35,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig([]byte(tt.config))
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestValidateExampleConfig(t *testing.T) {
+	data, err := os.ReadFile("../../config.json")
+	require.NoError(t, err)
+
+	assert.NoError(t, ValidateConfig(data))
+}
@@ -40,6 +40,16 @@ const (
 	RESPONSE_NONE         WebhookResponse = "NONE"
 )
 
+// Values lists the valid webhook triggers.
+func (WebhookTrigger) Values() []string {
+	return []string{string(STORE_TRANSACTION), string(UPDATE_TRANSACTION), string(DESTROY_TRANSACTION)}
+}
+
+// Values lists the valid webhook responses.
+func (WebhookResponse) Values() []string {
+	return []string{string(RESPONSE_TRANSACTIONS), string(RESPONSE_ACCOUNTS), string(RESPONSE_NONE)}
+}
+
 func (msg *WebhookMessage) UnmarshalJSON(b []byte) error {
 	// INFO: workaround to avoid infinite recursion
 	type TmpJson WebhookMessage
@@ -56,12 +66,11 @@ func (msg *WebhookMessage) UnmarshalJSON(b []byte) error {
 		}
 		res.Content = transaction
 	case RESPONSE_ACCOUNTS:
-	// TODO: implement this type
-	// var thing Something2
-	// if err := json.Unmarshal(msg.RawContent, &thing); err != nil {
-	// 	return err
-	// }
-	// msg.Content = thing
+		var accounts WebhookMessageAccounts
+		if err := json.Unmarshal(res.RawContent, &accounts); err != nil {
+			return err
+		}
+		res.Content = accounts
 	case RESPONSE_NONE:
 		res.Content = nil
 	}
@@ -103,3 +112,6 @@ type WebhookMessageTransaction struct {
 	ID           int                  `json:"id"`
 	User         int                  `json:"user"`
 }
+
+// WebhookMessageAccounts holds the accounts involved in the transaction triggering the webhook.
+type WebhookMessageAccounts []models.Account
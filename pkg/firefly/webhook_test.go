@@ -1,9 +1,12 @@
 package firefly
 
 import (
+	"encoding/json"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVerifySignature(t *testing.T) {
@@ -80,3 +83,21 @@ func TestVerifySignature(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalAccounts(t *testing.T) {
+	data, err := os.ReadFile("../../example/account.json")
+	require.NoError(t, err)
+
+	var msg WebhookMessage
+	require.NoError(t, json.Unmarshal(data, &msg))
+
+	accounts, ok := msg.Content.(WebhookMessageAccounts)
+	require.True(t, ok)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "1", accounts[0].ID)
+	assert.Equal(t, "Ticket Restaurant", accounts[0].Name)
+	assert.Equal(t, "-21.00", accounts[0].CurrentBalance)
+	assert.Equal(t, "defaultAsset", *accounts[0].AccountRole)
+	assert.Equal(t, "EUR", accounts[1].CurrencyCode)
+	assert.Nil(t, accounts[1].AccountRole)
+}
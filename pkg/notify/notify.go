@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Level is the severity of a notification.
+type Level string
+
+const (
+	INFO    Level = "info"
+	WARNING Level = "warning"
+)
+
+// Notification is a message sent to a notification sink.
+type Notification struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Level   Level  `json:"level"`
+}
+
+// Sink is a destination for notifications.
+type Sink interface {
+	// Notify sends the notification to the sink.
+	Notify(n Notification) error
+}
+
+// LogSink writes notifications to a logger.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink creates a new sink writing notifications to the given logger.
+func NewLogSink(logger *slog.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Notify logs the notification with a level matching its severity.
+func (s *LogSink) Notify(n Notification) error {
+	level := slog.LevelInfo
+	if n.Level == WARNING {
+		level = slog.LevelWarn
+	}
+	s.logger.Log(context.Background(), level, n.Title, "message", n.Message)
+	return nil
+}
+
+// WebhookSink sends notifications as JSON to an HTTP endpoint.
+type WebhookSink struct {
+	httpClient *http.Client
+	url        string
+}
+
+// NewWebhookSink creates a new sink posting notifications to the given URL.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		url: url,
+	}
+}
+
+// Notify posts the notification to the configured URL.
+func (s *WebhookSink) Notify(n Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.httpClient.Post(s.url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(r.Body)
+
+	if r.StatusCode < http.StatusOK || r.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("notification sink replied with status %s", r.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is a key value store used to remember state between webhook calls.
+// When a path is given, every change is persisted to a JSON file so the state survives restarts.
+type Store struct {
+	data map[string]string
+	mu   sync.Mutex
+	path string
+}
+
+// Open creates a new Store, loading the previous state from the given file if it exists.
+// An empty path creates an in-memory store.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		data: make(map[string]string),
+		path: path,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+	if err = json.Unmarshal(data, &s.data); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get returns the value stored for the given key.
+func (s *Store) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.data[key]
+	return value, ok
+}
+
+// Set stores the value for the given key.
+func (s *Store) Set(key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = value
+	return s.persist()
+}
+
+// Delete removes the given key from the store.
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, key)
+	return s.persist()
+}
+
+// persist writes the store to its file, replacing it atomically. Must be called holding the lock.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func(name string) {
+		_ = os.Remove(name)
+	}(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Set("foo", "bar"))
+	require.NoError(t, s.Set("baz", "qux"))
+	require.NoError(t, s.Delete("baz"))
+
+	reopened, err := Open(path)
+	require.NoError(t, err)
+	value, ok := reopened.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", value)
+	_, ok = reopened.Get("baz")
+	assert.False(t, ok)
+}